@@ -9,6 +9,7 @@ import (
 	"crypto/sha512"
 	"fmt"
 	"hash"
+	"strings"
 )
 
 const (
@@ -59,3 +60,25 @@ func (a Algorithm) Hash() func() hash.Hash {
 		return nil
 	}
 }
+
+// ParseAlgorithm parses the name of an algorithm like "SHA256" as used by
+// otpauth:// URIs and the exports of most authenticator apps.
+// The name is case-insensitive and dashes as well as an "HMAC" prefix are ignored.
+// An empty name denotes SHA1 which is the default of otpauth:// URIs.
+func ParseAlgorithm(s string) (Algorithm, error) {
+	n := strings.ToUpper(strings.ReplaceAll(s, "-", ""))
+
+	switch strings.TrimPrefix(n, "HMAC") {
+	case "SHA1", "":
+		return HmacSha1, nil
+
+	case "SHA256":
+		return HmacSha256, nil
+
+	case "SHA512":
+		return HmacSha512, nil
+
+	default:
+		return 0, fmt.Errorf("%w: %q", ErrInvalidAlgorithm, s)
+	}
+}
@@ -4,81 +4,171 @@
 package ykoath
 
 import (
+	"context"
 	"encoding/binary"
 	"errors"
 	"fmt"
 	"strings"
+	"time"
 
 	"cunicu.li/go-iso7816/encoding/tlv"
 )
 
 var (
-	ErrNoValuesFound         = errors.New("no values found in response")
-	ErrUnknownName           = errors.New("no such name configured")
-	ErrMultipleMatches       = errors.New("multiple matches found")
-	ErrTouchRequired         = errors.New("touch required")
-	ErrTouchCallbackRequired = errors.New("touch callback required")
-	ErrChallengeRequired     = errors.New("challenge required")
+	ErrNoValuesFound     = errors.New("no values found in response")
+	ErrUnknownName       = errors.New("no such name configured")
+	ErrMultipleMatches   = errors.New("multiple matches found")
+	ErrTouchRequired     = errors.New("touch required")
+	ErrChallengeRequired = errors.New("challenge required")
 )
 
+// Calculation is a single credential and its code as returned by CalculateAll
+type Calculation struct {
+	Name          string
+	ID            CredentialID
+	Type          Type
+	TouchRequired bool
+
+	// Code is nil for HOTP credentials and credentials which require touch
+	// as those are not calculated by the "CALCULATE ALL" instruction.
+	Code *Code
+
+	// ValidFrom and ValidUntil denote the time-step of TOTP codes.
+	// They are zero for credentials without a code.
+	ValidFrom  time.Time
+	ValidUntil time.Time
+}
+
+// OTP returns the one-time password or an empty string if no code has been calculated
+func (c *Calculation) OTP() string {
+	if c.Code == nil {
+		return ""
+	}
+
+	return c.Code.OTP()
+}
+
+// CalculateAll is a high-level function which calculates the codes of all
+// TOTP credentials which do not require touch.
+// Credentials with a period other than the card's time-step are re-calculated
+// using a challenge for their own period. Credentials of the issuer "Steam"
+// are re-calculated as well in order to render Steam Guard codes.
+func (c *Card) CalculateAll() ([]*Calculation, error) {
+	return c.CalculateAllContext(context.Background())
+}
+
+// CalculateAllContext is like CalculateAll but aborts when the context is done
+func (c *Card) CalculateAllContext(ctx context.Context) ([]*Calculation, error) {
+	if err := c.acquire(ctx); err != nil {
+		return nil, err
+	}
+
+	defer c.release()
+
+	now := c.Clock()
+
+	calcs, err := c.calculateAll(ctx, c.totpChallenge(now, c.Timestep), true)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, calc := range calcs {
+		if calc.Type != Totp || calc.TouchRequired {
+			continue
+		}
+
+		if calc.ID.Period != c.Timestep || calc.ID.IsSteam() {
+			code, err := c.calculateTOTP(ctx, calc.Name, calc.ID, now, false)
+			if err != nil {
+				return nil, err
+			}
+
+			calc.Code = &code
+		}
+
+		calc.ValidFrom, calc.ValidUntil = timeStep(now, calc.ID.Period)
+	}
+
+	return calcs, nil
+}
+
 // CalculateMatch is a high-level function that first identifies all TOTP credentials
 // that are configured and returns the matching one (if no touch is required) or
-// fires the callback and then fetches the name again while blocking during
+// notifies the TouchObserver and then fetches the name again while blocking during
 // the device awaiting touch
-func (c *Card) CalculateMatch(name string, touchRequiredCallback func(string) error) (string, error) {
-	totpChallenge := c.totpChallenge()
+func (c *Card) CalculateMatch(name string) (string, error) {
+	return c.CalculateMatchContext(context.Background(), name)
+}
 
-	codes, err := c.calculateAll(totpChallenge, true)
+// CalculateMatchContext is like CalculateMatch but aborts when the context is done.
+// This includes waiting for the user to touch the token.
+func (c *Card) CalculateMatchContext(ctx context.Context, name string) (string, error) {
+	if err := c.acquire(ctx); err != nil {
+		return "", err
+	}
+
+	defer c.release()
+
+	now := c.Clock()
+
+	calcs, err := c.calculateAll(ctx, c.totpChallenge(now, c.Timestep), true)
 	if err != nil {
 		return "", err
 	}
 
 	// Support matching by name without issuer in the same way that ykman does
 	// https://github.com/Yubico/yubikey-manager/blob/f493008d78a0ad09016f23dabd1cb658929d9c0e/ykman/cli/oath.py#L543
-	var key string
-	var code Code
+	var calc *Calculation
 	var matches []string
-	for k, c := range codes {
-		if strings.Contains(strings.ToLower(k), strings.ToLower(name)) {
-			key = k
-			code = c
-			matches = append(matches, k)
+	for _, e := range calcs {
+		if strings.Contains(strings.ToLower(e.Name), strings.ToLower(name)) {
+			calc = e
+			matches = append(matches, e.Name)
 		}
 	}
 	if len(matches) > 1 {
 		return "", fmt.Errorf("%w: %s", ErrMultipleMatches, strings.Join(matches, ","))
 	}
 
-	if key == "" {
+	if calc == nil {
 		return "", fmt.Errorf("%w: %s", ErrUnknownName, name)
 	}
 
-	if code.TouchRequired || code.Type == Hotp {
-		if code.TouchRequired {
-			if touchRequiredCallback == nil {
-				return "", ErrTouchCallbackRequired
-			}
-
-			if err := touchRequiredCallback(key); err != nil {
-				return "", err
-			}
-		}
-
-		var challenge []byte
-		if code.Type == Totp {
-			challenge = totpChallenge
+	if calc.Code == nil || calc.ID.Period != c.Timestep || calc.ID.IsSteam() {
+		var code Code
+		if calc.Type == Totp {
+			code, err = c.calculateTOTP(ctx, calc.Name, calc.ID, now, calc.TouchRequired)
+		} else {
+			code, err = c.calculate(ctx, calc.Name, nil, true, false)
 		}
-
-		if code, err = c.calculate(key, challenge, true); err != nil {
+		if err != nil {
 			return "", err
 		}
+
+		calc.Code = &code
 	}
 
-	return code.OTP(), nil
+	return calc.Code.OTP(), nil
 }
 
+// Calculate calculates the code of a single credential.
+// For TOTP credentials, the challenge is derived from the period encoded in the name.
+// Credentials of the issuer "Steam" are rendered as Steam Guard codes.
 func (c *Card) Calculate(name string) (string, error) {
-	d, err := c.calculate(name, c.totpChallenge(), true)
+	return c.CalculateContext(context.Background(), name)
+}
+
+// CalculateContext is like Calculate but aborts when the context is done
+func (c *Card) CalculateContext(ctx context.Context, name string) (string, error) {
+	id := parseName([]byte(name), Totp)
+
+	if err := c.acquire(ctx); err != nil {
+		return "", err
+	}
+
+	defer c.release()
+
+	d, err := c.calculateTOTP(ctx, name, id, c.Clock(), false)
 	if err != nil {
 		return "", err
 	}
@@ -87,7 +177,18 @@ func (c *Card) Calculate(name string) (string, error) {
 }
 
 func (c *Card) CalculateChallengeResponse(name string, challenge []byte) ([]byte, int, error) {
-	d, err := c.calculate(name, challenge, false)
+	return c.CalculateChallengeResponseContext(context.Background(), name, challenge)
+}
+
+// CalculateChallengeResponseContext is like CalculateChallengeResponse but aborts when the context is done
+func (c *Card) CalculateChallengeResponseContext(ctx context.Context, name string, challenge []byte) ([]byte, int, error) {
+	if err := c.acquire(ctx); err != nil {
+		return nil, -1, err
+	}
+
+	defer c.release()
+
+	d, err := c.calculate(ctx, name, challenge, false, false)
 	if err != nil {
 		return nil, -1, err
 	}
@@ -95,18 +196,23 @@ func (c *Card) CalculateChallengeResponse(name string, challenge []byte) ([]byte
 	return d.Hash, d.Digits, nil
 }
 
-// calculate implements the "CALCULATE" instruction
-func (c *Card) calculate(name string, challenge []byte, truncate bool) (Code, error) {
+// calculate implements the "CALCULATE" instruction.
+// The TouchObserver is notified immediately if the credential is known to require touch.
+func (c *Card) calculate(ctx context.Context, name string, challenge []byte, truncate, touch bool) (Code, error) {
 	var trunc byte
 	if truncate {
 		trunc = 0x01
 	}
 
-	tvs, err := c.send(insCalculate, 0x00, trunc,
-		tlv.New(tagName, []byte(name)),
-		tlv.New(tagChallenge, challenge),
-	)
-	if err != nil {
+	var tvs []tlv.TagValue
+
+	if err := c.withTouch(name, touch, func() (err error) {
+		tvs, err = c.send(ctx, insCalculate, 0x00, trunc,
+			tlv.New(tagName, []byte(name)),
+			tlv.New(tagChallenge, challenge),
+		)
+		return err
+	}); err != nil {
 		return Code{}, err
 	}
 
@@ -129,9 +235,23 @@ func (c *Card) calculate(name string, challenge []byte, truncate bool) (Code, er
 	return Code{}, ErrNoValuesFound
 }
 
+// calculateTOTP calculates the code of a TOTP credential for the time-step containing t.
+// The full response is requested for Steam credentials.
+func (c *Card) calculateTOTP(ctx context.Context, name string, id CredentialID, t time.Time, touch bool) (Code, error) {
+	code, err := c.calculate(ctx, name, c.totpChallenge(t, id.Period), !id.IsSteam(), touch)
+	if err != nil {
+		return Code{}, err
+	}
+
+	code.Type = Totp
+	code.Steam = id.IsSteam()
+
+	return code, nil
+}
+
 // calculateAll implements the "CALCULATE ALL" instruction to fetch all TOTP
 // tokens and their codes (or a constant indicating a touch requirement)
-func (c *Card) calculateAll(challenge []byte, truncate bool) (map[string]Code, error) {
+func (c *Card) calculateAll(ctx context.Context, challenge []byte, truncate bool) ([]*Calculation, error) {
 	var (
 		codes []Code
 		names []string
@@ -143,7 +263,7 @@ func (c *Card) calculateAll(challenge []byte, truncate bool) (map[string]Code, e
 		trunc = 0x01
 	}
 
-	tvs, err := c.send(insCalculateAll, 0x00, trunc,
+	tvs, err := c.send(ctx, insCalculateAll, 0x00, trunc,
 		tlv.New(tagChallenge, challenge),
 	)
 	if err != nil {
@@ -179,16 +299,50 @@ func (c *Card) calculateAll(challenge []byte, truncate bool) (map[string]Code, e
 		}
 	}
 
-	all := make(map[string]Code, len(names))
+	if len(names) != len(codes) {
+		return nil, ErrNoValuesFound
+	}
+
+	all := make([]*Calculation, 0, len(names))
 
 	for idx, name := range names {
-		all[name] = codes[idx]
+		code := codes[idx]
+		calc := &Calculation{
+			Name:          name,
+			Type:          code.Type,
+			TouchRequired: code.TouchRequired,
+		}
+
+		if code.Hash != nil {
+			calc.Code = &code
+		}
+
+		calc.ID = parseName([]byte(name), calc.Type)
+
+		all = append(all, calc)
 	}
 
 	return all, nil
 }
 
-func (c *Card) totpChallenge() []byte {
-	counter := c.Clock().Unix() / int64(c.Timestep.Seconds())
+func (c *Card) totpChallenge(t time.Time, period time.Duration) []byte {
+	counter := t.Unix() / periodSeconds(period)
 	return binary.BigEndian.AppendUint64(nil, uint64(counter)) // nolint:gosec
 }
+
+// timeStep returns the begin and end of the time-step containing t
+func timeStep(t time.Time, period time.Duration) (time.Time, time.Time) {
+	p := periodSeconds(period)
+	from := time.Unix(t.Unix()/p*p, 0)
+	return from, from.Add(time.Duration(p) * time.Second)
+}
+
+// periodSeconds returns the period in whole seconds.
+// Periods shorter than a second fall back to DefaultTimeStep.
+func periodSeconds(period time.Duration) int64 {
+	if p := int64(period / time.Second); p > 0 {
+		return p
+	}
+
+	return int64(DefaultTimeStep / time.Second)
+}
@@ -4,12 +4,13 @@
 package ykoath_test
 
 import (
-	"errors"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 
 	ykoath "cunicu.li/go-ykoath/v2"
+	"cunicu.li/go-ykoath/v2/emulator"
 )
 
 func TestCalculate(t *testing.T) {
@@ -20,7 +21,7 @@ func TestCalculate(t *testing.T) {
 		require := require.New(t)
 
 		for _, v := range vs {
-			code, err := card.CalculateMatch(v.Name, nil)
+			code, err := card.CalculateMatch(v.Name)
 			require.NoError(err)
 			require.Equal(v.Code, code)
 		}
@@ -31,10 +32,10 @@ func TestCalculateMatchPartial(t *testing.T) {
 	withCard(t, nil, func(t *testing.T, card *ykoath.Card) {
 		require := require.New(t)
 
-		err := card.Put("testvector", ykoath.HmacSha1, ykoath.Totp, 8, testSecretSHA1, false, 0)
+		err := card.Put(ykoath.CredentialID{Account: "testvector"}, ykoath.HmacSha1, ykoath.Totp, 8, testSecretSHA1, false, 0)
 		require.NoError(err)
 
-		res, err := card.CalculateMatch("test", nil)
+		res, err := card.CalculateMatch("test")
 		require.NoError(err)
 		require.Equal("94287082", res)
 	})
@@ -44,10 +45,10 @@ func TestCalculateMatchFull(t *testing.T) {
 	withCard(t, nil, func(t *testing.T, card *ykoath.Card) {
 		require := require.New(t)
 
-		err := card.Put("testvector", ykoath.HmacSha1, ykoath.Totp, 8, testSecretSHA1, false, 0)
+		err := card.Put(ykoath.CredentialID{Account: "testvector"}, ykoath.HmacSha1, ykoath.Totp, 8, testSecretSHA1, false, 0)
 		require.NoError(err)
 
-		res, err := card.CalculateMatch("testvector", nil)
+		res, err := card.CalculateMatch("testvector")
 		require.NoError(err)
 		require.Equal("94287082", res)
 	})
@@ -57,13 +58,13 @@ func TestCalculateMatchMultiple(t *testing.T) {
 	withCard(t, nil, func(t *testing.T, card *ykoath.Card) {
 		require := require.New(t)
 
-		err := card.Put("testvector1", ykoath.HmacSha1, ykoath.Totp, 8, testSecretSHA1, false, 0)
+		err := card.Put(ykoath.CredentialID{Account: "testvector1"}, ykoath.HmacSha1, ykoath.Totp, 8, testSecretSHA1, false, 0)
 		require.NoError(err)
 
-		err = card.Put("testvector2", ykoath.HmacSha1, ykoath.Totp, 8, testSecretSHA1, false, 0)
+		err = card.Put(ykoath.CredentialID{Account: "testvector2"}, ykoath.HmacSha1, ykoath.Totp, 8, testSecretSHA1, false, 0)
 		require.NoError(err)
 
-		_, err = card.CalculateMatch("test", nil)
+		_, err = card.CalculateMatch("test")
 		require.ErrorIs(err, ykoath.ErrMultipleMatches)
 	})
 }
@@ -81,25 +82,13 @@ func TestCalculateRequireTouch(t *testing.T) {
 	}, func(t *testing.T, card *ykoath.Card) {
 		require := require.New(t)
 
-		// Callback missing
-		_, err := card.CalculateMatch("touch", nil)
-		require.ErrorIs(err, ykoath.ErrTouchCallbackRequired)
-
-		// Error raised in callback
-		_, err = card.CalculateMatch("touch", func(_ string) error {
-			return errors.New("my error") //nolint:err113
-		})
-		require.ErrorContains(err, "my error")
-
-		// Callback called but button not pressed
-		touchRequested := false
-		_, err = card.CalculateMatch("touch", func(s string) error {
-			require.Equal(s, "touch required")
-			touchRequested = true
-			return nil
-		})
-		require.NoError(err)
-		require.True(touchRequested)
+		obs := &touchRecorder{}
+		card.TouchObserver = obs
+
+		code, err := card.CalculateMatch("touch")
+		require.NoError(err)
+		require.Equal("904283", code)
+		require.Equal([]string{"awaited touch required", "received touch required"}, obs.events)
 	})
 }
 
@@ -152,3 +141,98 @@ func TestCalculateRAW(t *testing.T) {
 		require.Len(resp, v.Alg.Hash()().Size())
 	})
 }
+
+func TestCalculateAll(t *testing.T) {
+	vs := vectorsTOTP[:1]
+	withEmulator(t, emulator.NewCard(), vs, func(t *testing.T, card *ykoath.Card) {
+		require := require.New(t)
+
+		creds := []ykoath.CredentialData{
+			{ID: ykoath.CredentialID{Period: 15 * time.Second, Issuer: "Example", Account: "fifteen"}, Type: ykoath.Totp},
+			{ID: ykoath.CredentialID{Period: 60 * time.Second, Account: "sixty"}, Type: ykoath.Totp},
+			{ID: ykoath.CredentialID{Issuer: "Example", Account: "hotp"}, Type: ykoath.Hotp},
+			{ID: ykoath.CredentialID{Account: "touch"}, Type: ykoath.Totp, Touch: true},
+		}
+
+		for _, cred := range creds {
+			cred.Algorithm = ykoath.HmacSha1
+			cred.Digits = 8
+			cred.Secret = testSecretSHA1
+
+			err := card.PutCredential(&cred)
+			require.NoError(err)
+		}
+
+		calcs, err := card.CalculateAll()
+		require.NoError(err)
+		require.Len(calcs, 5)
+
+		byName := map[string]*ykoath.Calculation{}
+		for _, calc := range calcs {
+			byName[calc.Name] = calc
+		}
+
+		calc := byName[vs[0].Name]
+		require.Equal(ykoath.Totp, calc.Type)
+		require.Equal(vs[0].Code, calc.OTP())
+		require.Equal(time.Unix(30, 0), calc.ValidFrom)
+		require.Equal(time.Unix(60, 0), calc.ValidUntil)
+
+		calc = byName["15/Example:fifteen"]
+		require.Equal(creds[0].ID, calc.ID)
+		require.Equal("26969429", calc.OTP())
+		require.Equal(time.Unix(45, 0), calc.ValidFrom)
+		require.Equal(time.Unix(60, 0), calc.ValidUntil)
+
+		calc = byName["60/sixty"]
+		require.Equal(creds[1].ID, calc.ID)
+		require.Equal("84755224", calc.OTP())
+		require.Equal(time.Unix(0, 0), calc.ValidFrom)
+		require.Equal(time.Unix(60, 0), calc.ValidUntil)
+
+		calc = byName["Example:hotp"]
+		require.Equal(creds[2].ID, calc.ID)
+		require.Equal(ykoath.Hotp, calc.Type)
+		require.Nil(calc.Code)
+		require.Empty(calc.OTP())
+		require.True(calc.ValidFrom.IsZero())
+
+		calc = byName["touch"]
+		require.Equal(ykoath.Totp, calc.Type)
+		require.True(calc.TouchRequired)
+		require.Nil(calc.Code)
+
+		// Codes of credentials with a non-default period are also
+		// calculated with the right challenge when matched directly
+		code, err := card.CalculateMatch("fifteen")
+		require.NoError(err)
+		require.Equal("26969429", code)
+
+		code, err = card.Calculate("60/sixty")
+		require.NoError(err)
+		require.Equal("84755224", code)
+	})
+}
+
+func TestCalculateSteam(t *testing.T) {
+	withEmulator(t, emulator.NewCard(), nil, func(t *testing.T, card *ykoath.Card) {
+		require := require.New(t)
+
+		err := card.Put(ykoath.CredentialID{Issuer: ykoath.SteamIssuer, Account: "gaben"}, ykoath.HmacSha1, ykoath.Totp, 6, testSecretSHA1, false, 0)
+		require.NoError(err)
+
+		calcs, err := card.CalculateAll()
+		require.NoError(err)
+		require.Len(calcs, 1)
+		require.True(calcs[0].Code.Steam)
+		require.Equal("PV9M4", calcs[0].OTP())
+
+		code, err := card.CalculateMatch("gaben")
+		require.NoError(err)
+		require.Equal("PV9M4", code)
+
+		code, err = card.Calculate("Steam:gaben")
+		require.NoError(err)
+		require.Equal("PV9M4", code)
+	})
+}
@@ -0,0 +1,596 @@
+// SPDX-FileCopyrightText: 2023 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	iso "cunicu.li/go-iso7816"
+
+	ykoath "cunicu.li/go-ykoath/v2"
+	"cunicu.li/go-ykoath/v2/manifest"
+)
+
+type credentialJSON struct {
+	Name      string `json:"name"`
+	Issuer    string `json:"issuer,omitempty"`
+	Account   string `json:"account"`
+	Type      string `json:"type"`
+	Algorithm string `json:"algorithm,omitempty"`
+	Period    int    `json:"period,omitempty"`
+}
+
+type codeJSON struct {
+	credentialJSON
+
+	Code          string     `json:"code,omitempty"`
+	TouchRequired bool       `json:"touch_required,omitempty"`
+	ValidFrom     *time.Time `json:"valid_from,omitempty"`
+	ValidUntil    *time.Time `json:"valid_until,omitempty"`
+}
+
+type deviceJSON struct {
+	Reader   string `json:"reader"`
+	Version  string `json:"version"`
+	DeviceID string `json:"device_id"`
+	Serial   uint32 `json:"serial,omitempty"`
+}
+
+type infoJSON struct {
+	Reader            string `json:"reader,omitempty"`
+	Version           string `json:"version"`
+	DeviceID          string `json:"device_id"`
+	Salt              string `json:"salt"`
+	PasswordProtected bool   `json:"password_protected"`
+	Algorithm         string `json:"algorithm,omitempty"`
+}
+
+func newCredentialJSON(name string, id ykoath.CredentialID, typ ykoath.Type) credentialJSON {
+	return credentialJSON{
+		Name:    name,
+		Issuer:  id.Issuer,
+		Account: id.Account,
+		Type:    typ.String(),
+		Period:  int(id.Period / time.Second),
+	}
+}
+
+func (a *app) list(args []string) error {
+	flags := a.newFlagSet("list", "")
+	if err := a.parse(flags, args, 0); err != nil {
+		return err
+	}
+
+	card, _, err := a.connect()
+	if err != nil {
+		return err
+	}
+
+	names, err := card.List()
+	if err != nil {
+		return fmt.Errorf("failed to list credentials: %w", err)
+	}
+
+	creds := []credentialJSON{}
+	rows := [][]string{}
+
+	for _, n := range names {
+		cred := newCredentialJSON(n.Name, n.ID, n.Type)
+		cred.Algorithm = n.Algorithm.String()
+
+		creds = append(creds, cred)
+		rows = append(rows, []string{n.Name, n.Type.String(), n.Algorithm.String()})
+	}
+
+	return a.output(creds, []string{"NAME", "TYPE", "ALGORITHM"}, rows)
+}
+
+func (a *app) add(args []string) error {
+	flags := a.newFlagSet("add", "<account|uri>")
+
+	var (
+		issuer    = flags.String("issuer", "", "Issuer of the credential")
+		secret    = flags.String("secret", "", "Base32 encoded secret")
+		typ       = flags.String("type", "totp", "Type of the credential (totp, hotp)")
+		algorithm = flags.String("algorithm", "SHA1", "HMAC algorithm (SHA1, SHA256, SHA512)")
+		digits    = flags.Int("digits", ykoath.MinDigits, "Number of digits")
+		period    = flags.Int("period", int(ykoath.DefaultTimeStep/time.Second), "Time-step of TOTP credentials in seconds")
+		counter   = flags.Uint("counter", 0, "Initial counter of HOTP credentials")
+		touch     = flags.Bool("touch", false, "Require touch for calculating codes")
+		force     = flags.Bool("force", false, "Overwrite an existing credential without confirmation")
+	)
+
+	if err := a.parse(flags, args, 1); err != nil {
+		return err
+	}
+
+	uri := flags.Arg(0)
+	if !strings.HasPrefix(uri, "otpauth://") {
+		q := url.Values{}
+		q.Set("secret", *secret)
+		q.Set("algorithm", *algorithm)
+		q.Set("digits", strconv.Itoa(*digits))
+		q.Set("period", strconv.Itoa(*period))
+		q.Set("counter", strconv.FormatUint(uint64(*counter), 10))
+
+		label := uri
+		if *issuer != "" {
+			label = *issuer + ":" + label
+		}
+
+		u := url.URL{
+			Scheme:   "otpauth",
+			Host:     *typ,
+			Path:     "/" + label,
+			RawQuery: q.Encode(),
+		}
+
+		uri = u.String()
+	}
+
+	d, err := ykoath.ParseURI(uri)
+	if err != nil {
+		return err
+	}
+
+	d.Touch = *touch
+
+	card, _, err := a.connect()
+	if err != nil {
+		return err
+	}
+
+	if !*force {
+		names, err := card.List()
+		if err != nil {
+			return fmt.Errorf("failed to list credentials: %w", err)
+		}
+
+		for _, n := range names {
+			if n.Name == d.ID.String() {
+				if err := a.confirm(fmt.Sprintf("Credential %q already exists. Overwrite?", n.Name)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	if err := card.PutCredential(d); err != nil {
+		return fmt.Errorf("failed to add credential: %w", err)
+	}
+
+	return a.output(newCredentialJSON(d.ID.String(), d.ID, d.Type), nil, [][]string{{"Added credential " + d.ID.String()}})
+}
+
+func (a *app) code(args []string) error {
+	flags := a.newFlagSet("code", "[match]")
+	if err := a.parse(flags, args, -1); err != nil {
+		return err
+	} else if flags.NArg() > 1 {
+		flags.Usage()
+		return fmt.Errorf("%w: too many arguments", errUsage)
+	}
+
+	card, _, err := a.connect()
+	if err != nil {
+		return err
+	}
+
+	if match := flags.Arg(0); match != "" {
+		card.TouchObserver = &touchPrompt{a.stderr}
+
+		code, err := card.CalculateMatch(match)
+		if err != nil {
+			return fmt.Errorf("failed to calculate code: %w", err)
+		}
+
+		return a.output(codeJSON{
+			credentialJSON: credentialJSON{Name: match},
+			Code:           code,
+		}, nil, [][]string{{code}})
+	}
+
+	calcs, err := card.CalculateAll()
+	if err != nil {
+		return fmt.Errorf("failed to calculate codes: %w", err)
+	}
+
+	codes := []codeJSON{}
+	rows := [][]string{}
+
+	for _, calc := range calcs {
+		code := codeJSON{
+			credentialJSON: newCredentialJSON(calc.Name, calc.ID, calc.Type),
+			Code:           calc.OTP(),
+			TouchRequired:  calc.TouchRequired,
+		}
+
+		if calc.Code != nil {
+			code.ValidFrom = &calc.ValidFrom
+			code.ValidUntil = &calc.ValidUntil
+		}
+
+		row := []string{calc.Name, code.Code}
+
+		switch {
+		case calc.TouchRequired:
+			row[1] = "[Requires Touch]"
+
+		case calc.Type == ykoath.Hotp:
+			row[1] = "[HOTP Credential]"
+		}
+
+		codes = append(codes, code)
+		rows = append(rows, row)
+	}
+
+	return a.output(codes, []string{"NAME", "CODE"}, rows)
+}
+
+// touchPrompt asks the user to touch the token
+type touchPrompt struct {
+	w io.Writer
+}
+
+func (p *touchPrompt) TouchAwaited(name string) {
+	fmt.Fprintf(p.w, "Touch your YubiKey to calculate the code of %q...\n", name)
+}
+
+func (p *touchPrompt) TouchReceived(string, time.Duration) {}
+
+func (p *touchPrompt) TouchTimedOut(string, time.Duration) {
+	fmt.Fprintln(p.w, "Timed out while waiting for touch")
+}
+
+func (a *app) delete(args []string) error {
+	flags := a.newFlagSet("delete", "<name>")
+	if err := a.parse(flags, args, 1); err != nil {
+		return err
+	}
+
+	card, _, err := a.connect()
+	if err != nil {
+		return err
+	}
+
+	name := flags.Arg(0)
+
+	if err := card.Delete(name); err != nil {
+		if errors.Is(err, ykoath.ErrNoSuchObject) {
+			return fmt.Errorf("%w: %s", ykoath.ErrUnknownName, name)
+		}
+
+		return fmt.Errorf("failed to delete credential: %w", err)
+	}
+
+	return a.output(credentialJSON{Name: name}, nil, [][]string{{"Deleted credential " + name}})
+}
+
+func (a *app) rename(args []string) error {
+	flags := a.newFlagSet("rename", "<name> <new-name>")
+	if err := a.parse(flags, args, 2); err != nil {
+		return err
+	}
+
+	card, _, err := a.connect()
+	if err != nil {
+		return err
+	}
+
+	oldName, newName := flags.Arg(0), flags.Arg(1)
+
+	if err := card.Rename(oldName, newName); err != nil {
+		return fmt.Errorf("failed to rename credential: %w", err)
+	}
+
+	return a.output(credentialJSON{Name: newName}, nil, [][]string{{"Renamed credential " + oldName + " to " + newName}})
+}
+
+func (a *app) reset(args []string) error {
+	flags := a.newFlagSet("reset", "")
+	force := flags.Bool("force", false, "Reset without confirmation")
+
+	if err := a.parse(flags, args, 0); err != nil {
+		return err
+	}
+
+	card, _, err := a.connect()
+	if err != nil {
+		return err
+	}
+
+	if !*force {
+		if err := a.confirm("WARNING! This will delete all credentials and remove the password. Continue?"); err != nil {
+			return err
+		}
+	}
+
+	if err := card.Reset(); err != nil {
+		return fmt.Errorf("failed to reset applet: %w", err)
+	}
+
+	return a.output(struct{}{}, nil, [][]string{{"Reset the OATH applet"}})
+}
+
+func (a *app) password(args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("%w: missing sub-command (set, remove, validate)", errUsage)
+	}
+
+	sub, args := args[0], args[1:]
+
+	flags := a.newFlagSet("password "+sub, "")
+	nargs := 0
+
+	if sub == "set" {
+		flags = a.newFlagSet("password set", "<new-password>")
+		nargs = 1
+	}
+
+	if err := a.parse(flags, args, nargs); err != nil {
+		return err
+	}
+
+	card, sel, err := a.connect()
+	if err != nil {
+		return err
+	}
+
+	var msg string
+
+	switch sub {
+	case "set":
+		// Authenticate first as the current password is required for changing it
+		if sel.Challenge != nil && !card.Authenticated() {
+			if err := a.validate(card); err != nil {
+				return err
+			}
+		}
+
+		if err := card.SetCode([]byte(flags.Arg(0)), ykoath.HmacSha1); err != nil {
+			return fmt.Errorf("failed to set password: %w", err)
+		}
+
+		msg = "Password has been set"
+
+	case "remove":
+		if sel.Challenge == nil {
+			msg = "No password is set"
+			break
+		}
+
+		if err := a.validate(card); err != nil {
+			return err
+		}
+
+		if err := card.RemoveCode(); err != nil {
+			return fmt.Errorf("failed to remove password: %w", err)
+		}
+
+		msg = "Password has been removed"
+
+	case "validate":
+		if sel.Challenge == nil {
+			msg = "No password is set"
+			break
+		}
+
+		if err := a.validate(card); err != nil {
+			return err
+		}
+
+		msg = "Password is valid"
+
+	default:
+		return fmt.Errorf("%w: %s", errUnknownCommand, sub)
+	}
+
+	return a.output(struct {
+		Message string `json:"message"`
+	}{msg}, nil, [][]string{{msg}})
+}
+
+func (a *app) validate(card *ykoath.Card) error {
+	pw, err := a.getPassword("Current password: ")
+	if err != nil {
+		return err
+	}
+
+	if err := card.Validate(pw); err != nil {
+		if ykoath.IsWrongKey(err) {
+			return fmt.Errorf("wrong password: %w", err)
+		}
+
+		return fmt.Errorf("failed to validate password: %w", err)
+	}
+
+	return nil
+}
+
+func (a *app) info(args []string) error {
+	flags := a.newFlagSet("info", "")
+	if err := a.parse(flags, args, 0); err != nil {
+		return err
+	}
+
+	_, sel, err := a.connect()
+	if err != nil {
+		return err
+	}
+
+	info := infoJSON{
+		Version:           sel.Version.String(),
+		DeviceID:          sel.DeviceID(),
+		Salt:              hex.EncodeToString(sel.Salt()),
+		PasswordProtected: sel.Challenge != nil,
+	}
+
+	if rc, ok := a.card.Base().(iso.ReaderCard); ok {
+		info.Reader = rc.Reader()
+	}
+
+	if len(sel.Algorithm) > 0 {
+		info.Algorithm = ykoath.Algorithm(sel.Algorithm[0]).String()
+	}
+
+	rows := [][]string{
+		{"Version:", info.Version},
+		{"Device ID:", info.DeviceID},
+		{"Password protected:", strconv.FormatBool(info.PasswordProtected)},
+	}
+
+	if info.Reader != "" {
+		rows = append([][]string{{"Reader:", info.Reader}}, rows...)
+	}
+
+	return a.output(info, nil, rows)
+}
+
+func (a *app) readers(args []string) error {
+	flags := a.newFlagSet("readers", "")
+	if err := a.parse(flags, args, 0); err != nil {
+		return err
+	}
+
+	readers, err := a.listReaders()
+	if err != nil {
+		return fmt.Errorf("failed to list readers: %w", err)
+	}
+
+	rows := [][]string{}
+	for _, r := range readers {
+		rows = append(rows, []string{r})
+	}
+
+	return a.output(readers, nil, rows)
+}
+
+func (a *app) devices(args []string) error {
+	flags := a.newFlagSet("devices", "")
+	if err := a.parse(flags, args, 0); err != nil {
+		return err
+	}
+
+	infos, err := a.listDevices()
+	if err != nil {
+		return fmt.Errorf("failed to list devices: %w", err)
+	}
+
+	devs := []deviceJSON{}
+	rows := [][]string{}
+
+	for _, info := range infos {
+		dev := deviceJSON{
+			Reader:   info.Reader,
+			Version:  info.Version.String(),
+			DeviceID: info.DeviceID,
+			Serial:   info.Serial,
+		}
+
+		serial := ""
+		if info.Serial != 0 {
+			serial = strconv.FormatUint(uint64(info.Serial), 10)
+		}
+
+		devs = append(devs, dev)
+		rows = append(rows, []string{dev.Reader, dev.Version, dev.DeviceID, serial})
+	}
+
+	return a.output(devs, []string{"READER", "VERSION", "DEVICE ID", "SERIAL"}, rows)
+}
+
+func (a *app) newFlagSet(name, arguments string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(a.stderr)
+	flags.Usage = func() {
+		fmt.Fprintf(a.stderr, "Usage: ykoath %s [flags] %s\n", name, arguments)
+		flags.PrintDefaults()
+	}
+
+	return flags
+}
+
+// parse parses the flags of a command and checks the number of
+// positional arguments unless nargs is negative
+func (a *app) parse(flags *flag.FlagSet, args []string, nargs int) error {
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if nargs >= 0 && flags.NArg() != nargs {
+		flags.Usage()
+		return fmt.Errorf("%w: expected %d arguments, got %d", errUsage, nargs, flags.NArg())
+	}
+
+	return nil
+}
+
+type changeJSON struct {
+	Action string `json:"action"`
+	Name   string `json:"name"`
+}
+
+func (a *app) apply(args []string) error {
+	flags := a.newFlagSet("apply", "<manifest>")
+
+	var (
+		prune  = flags.Bool("prune", false, "Delete credentials which are not listed in the manifest")
+		dryRun = flags.Bool("dry-run", false, "Only show the plan without modifying the token")
+		force  = flags.Bool("force", false, "Apply the plan without confirmation")
+	)
+
+	if err := a.parse(flags, args, 1); err != nil {
+		return err
+	}
+
+	m, err := manifest.Load(flags.Arg(0))
+	if err != nil {
+		return fmt.Errorf("failed to load manifest: %w", err)
+	}
+
+	card, _, err := a.connect()
+	if err != nil {
+		return err
+	}
+
+	opts := &manifest.Options{
+		Prune:  *prune,
+		DryRun: *dryRun,
+	}
+
+	if !*force {
+		opts.Confirm = func(p *manifest.Plan) error {
+			fmt.Fprint(a.stderr, p)
+
+			return a.confirm("Apply these changes?")
+		}
+	}
+
+	p, err := manifest.Apply(context.Background(), card, m, opts)
+	if err != nil {
+		return fmt.Errorf("failed to apply manifest: %w", err)
+	}
+
+	changes := []changeJSON{}
+	rows := [][]string{}
+
+	for _, c := range p.Changes {
+		changes = append(changes, changeJSON{
+			Action: c.Action.String(),
+			Name:   c.Name,
+		})
+		rows = append(rows, []string{c.Action.String(), c.Name})
+	}
+
+	return a.output(changes, []string{"ACTION", "NAME"}, rows)
+}
@@ -0,0 +1,343 @@
+// SPDX-FileCopyrightText: 2023 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+// Command ykoath manages the OATH credentials of YubiKeys.
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/ebfe/scard"
+	"golang.org/x/term"
+
+	ykoath "cunicu.li/go-ykoath/v2"
+	"cunicu.li/go-ykoath/v2/device"
+)
+
+var (
+	errUsage           = errors.New("invalid usage")
+	errUnknownCommand  = errors.New("unknown command")
+	errAborted         = errors.New("aborted")
+	errPasswordMissing = errors.New("password required")
+)
+
+const envPassword = "YKOATH_PASSWORD"
+
+const usage = `Usage: ykoath [flags] <command> [arguments]
+
+Commands:
+  list                          List all credentials
+  add [flags] <account|uri>     Add a credential from flags or an otpauth:// URI
+  code [match]                  Calculate codes of all credentials or a single matching one
+  delete <name>                 Delete a credential
+  rename <name> <new-name>      Rename a credential
+  reset [-force]                Reset the applet and delete all credentials
+  password set <new-password>   Set or change the password
+  password remove               Remove the password
+  password validate             Validate the password
+  info                          Show information about the applet
+  apply [flags] <manifest>      Add credentials from a manifest and optionally delete unlisted ones
+  readers                       List all smart card readers
+  devices                       List all tokens with the OATH applet
+
+Flags:
+`
+
+type command func(a *app, args []string) error
+
+var commands = map[string]command{ //nolint:gochecknoglobals
+	"list":     (*app).list,
+	"add":      (*app).add,
+	"code":     (*app).code,
+	"delete":   (*app).delete,
+	"rename":   (*app).rename,
+	"reset":    (*app).reset,
+	"password": (*app).password,
+	"info":     (*app).info,
+	"apply":    (*app).apply,
+	"readers":  (*app).readers,
+	"devices":  (*app).devices,
+}
+
+// app contains the global state of the command.
+type app struct {
+	stdin  *bufio.Reader
+	stdout io.Writer
+	stderr io.Writer
+
+	json   bool
+	reader string
+	device string
+	pass   string
+
+	// open connects to the card whose reader name and identifier match
+	open        func(reader, id string) (*ykoath.Card, error)
+	listReaders func() ([]string, error)
+	listDevices func() ([]*device.Info, error)
+
+	// readPassword reads a password without echoing it.
+	// It is nil if stdin is not a terminal.
+	readPassword func() ([]byte, error)
+
+	card *ykoath.Card
+	ctx  *scard.Context
+}
+
+func main() {
+	a := &app{
+		stdin:       bufio.NewReader(os.Stdin),
+		stdout:      os.Stdout,
+		stderr:      os.Stderr,
+		listReaders: listReaders,
+		listDevices: listDevices,
+	}
+
+	a.open = a.openPCSC
+
+	if fd := int(os.Stdin.Fd()); term.IsTerminal(fd) {
+		a.readPassword = func() ([]byte, error) {
+			return term.ReadPassword(fd)
+		}
+	}
+
+	if err := a.run(os.Args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+
+		if errors.Is(err, errUsage) || errors.Is(err, flag.ErrHelp) {
+			os.Exit(2)
+		}
+
+		os.Exit(1)
+	}
+}
+
+func (a *app) run(args []string) error {
+	flags := flag.NewFlagSet("ykoath", flag.ContinueOnError)
+	flags.SetOutput(a.stderr)
+	flags.Usage = func() {
+		fmt.Fprint(a.stderr, usage)
+		flags.PrintDefaults()
+	}
+
+	flags.BoolVar(&a.json, "json", false, "Print output as JSON")
+	flags.StringVar(&a.reader, "reader", "", "Use the reader whose name contains the `string`")
+	flags.StringVar(&a.device, "device", "", "Use the token with the given serial number or device `ID`")
+	flags.StringVar(&a.pass, "password", "", "Password of the applet (also read from $"+envPassword+")")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if a.pass == "" {
+		a.pass = os.Getenv(envPassword)
+	}
+
+	if flags.NArg() < 1 {
+		flags.Usage()
+		return fmt.Errorf("%w: missing command", errUsage)
+	}
+
+	name := flags.Arg(0)
+
+	cmd, ok := commands[name]
+	if !ok {
+		flags.Usage()
+		return fmt.Errorf("%w: %s", errUnknownCommand, name)
+	}
+
+	err := cmd(a, flags.Args()[1:])
+
+	if a.card != nil {
+		if cerr := a.card.Close(); cerr != nil && err == nil {
+			err = fmt.Errorf("failed to close card: %w", cerr)
+		}
+
+		if cerr := a.card.Card.Close(); cerr != nil && err == nil {
+			err = fmt.Errorf("failed to disconnect card: %w", cerr)
+		}
+	}
+
+	if a.ctx != nil {
+		if cerr := a.ctx.Release(); cerr != nil && err == nil {
+			err = fmt.Errorf("failed to release context: %w", cerr)
+		}
+	}
+
+	return err
+}
+
+// connect opens the card and selects the OATH applet
+func (a *app) connect() (*ykoath.Card, *ykoath.Select, error) {
+	var err error
+
+	if a.card, err = a.open(a.reader, a.device); err != nil {
+		return nil, nil, err
+	}
+
+	a.card.PasswordProvider = func(*ykoath.Select) ([]byte, error) {
+		return a.getPassword("Password: ")
+	}
+
+	sel, err := a.card.Select()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to select applet: %w", err)
+	}
+
+	return a.card, sel, nil
+}
+
+// getPassword returns the password passed by flag or environment variable or prompts for it
+func (a *app) getPassword(prompt string) ([]byte, error) {
+	if a.pass != "" {
+		return []byte(a.pass), nil
+	}
+
+	if a.readPassword != nil {
+		fmt.Fprint(a.stderr, prompt)
+
+		pw, err := a.readPassword()
+		fmt.Fprintln(a.stderr)
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to read password: %w", err)
+		} else if len(pw) == 0 {
+			return nil, errPasswordMissing
+		}
+
+		return pw, nil
+	}
+
+	pw, err := a.prompt(prompt)
+	if err != nil {
+		return nil, err
+	} else if pw == "" {
+		return nil, errPasswordMissing
+	}
+
+	return []byte(pw), nil
+}
+
+func (a *app) prompt(prompt string) (string, error) {
+	fmt.Fprint(a.stderr, prompt)
+
+	line, err := a.stdin.ReadString('\n')
+	if err != nil && (!errors.Is(err, io.EOF) || line == "") {
+		return "", fmt.Errorf("failed to read from stdin: %w", err)
+	}
+
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// confirm asks the user for confirmation
+func (a *app) confirm(question string) error {
+	answer, err := a.prompt(question + " [y/N] ")
+	if err != nil {
+		return err
+	}
+
+	switch strings.ToLower(answer) {
+	case "y", "yes":
+		return nil
+
+	default:
+		return errAborted
+	}
+}
+
+// output prints v as JSON or the rows as a table with an optional header
+func (a *app) output(v any, header []string, rows [][]string) error {
+	if a.json {
+		enc := json.NewEncoder(a.stdout)
+		enc.SetIndent("", "  ")
+
+		return enc.Encode(v)
+	}
+
+	w := tabwriter.NewWriter(a.stdout, 0, 0, 2, ' ', 0)
+
+	if header != nil {
+		fmt.Fprintln(w, strings.Join(header, "\t"))
+	}
+
+	for _, row := range rows {
+		fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+
+	return w.Flush()
+}
+
+// openPCSC connects to the token which is identified by id in the reader whose name contains reader.
+// The PC/SC context is kept until the command has completed.
+func (a *app) openPCSC(reader, id string) (*ykoath.Card, error) {
+	ctx, err := scard.EstablishContext()
+	if err != nil {
+		return nil, fmt.Errorf("failed to establish context: %w", err)
+	}
+
+	a.ctx = ctx
+
+	// Narrow the tokens down to a single reader as device.Open
+	// only matches the complete name of a reader.
+	if reader != "" {
+		infos, err := device.List(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		var readers []string
+
+		for _, info := range infos {
+			if strings.Contains(strings.ToLower(info.Reader), strings.ToLower(reader)) && (id == "" || info.Matches(id)) {
+				readers = append(readers, info.Reader)
+			}
+		}
+
+		switch len(readers) {
+		case 0:
+			return nil, fmt.Errorf("%w: reader %s", device.ErrNotFound, reader)
+
+		case 1:
+			id = readers[0]
+
+		default:
+			return nil, fmt.Errorf("%w: %d readers match %s", device.ErrAmbiguous, len(readers), reader)
+		}
+	}
+
+	card, _, err := device.Open(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to card: %w", err)
+	}
+
+	return card, nil
+}
+
+func listReaders() ([]string, error) {
+	ctx, err := scard.EstablishContext()
+	if err != nil {
+		return nil, fmt.Errorf("failed to establish context: %w", err)
+	}
+
+	defer ctx.Release() //nolint:errcheck
+
+	return ctx.ListReaders()
+}
+
+func listDevices() ([]*device.Info, error) {
+	ctx, err := scard.EstablishContext()
+	if err != nil {
+		return nil, fmt.Errorf("failed to establish context: %w", err)
+	}
+
+	defer ctx.Release() //nolint:errcheck
+
+	return device.List(ctx)
+}
@@ -0,0 +1,252 @@
+// SPDX-FileCopyrightText: 2023 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	ykoath "cunicu.li/go-ykoath/v2"
+	"cunicu.li/go-ykoath/v2/device"
+	"cunicu.li/go-ykoath/v2/emulator"
+)
+
+const testURI = "otpauth://totp/Example:alice@example.com?secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ&issuer=Example"
+
+func run(t *testing.T, emu *emulator.Card, stdin string, args ...string) (string, error) {
+	t.Helper()
+
+	var stdout, stderr bytes.Buffer
+
+	a := &app{
+		stdin:  bufio.NewReader(strings.NewReader(stdin)),
+		stdout: &stdout,
+		stderr: &stderr,
+		open: func(_, id string) (*ykoath.Card, error) {
+			if id != "" {
+				if match, err := device.HasID(id)(emu); err != nil {
+					return nil, err
+				} else if !match {
+					return nil, device.ErrNotFound
+				}
+			}
+
+			return ykoath.NewCard(emu)
+		},
+		listReaders: func() ([]string, error) {
+			return []string{"Yubico YubiKey OTP+FIDO+CCID 00 00"}, nil
+		},
+		listDevices: func() ([]*device.Info, error) {
+			info, err := device.Probe(emu)
+			if err != nil {
+				return nil, err
+			}
+
+			return []*device.Info{info}, nil
+		},
+	}
+
+	err := a.run(args)
+
+	return stdout.String(), err
+}
+
+func TestCredentials(t *testing.T) {
+	require := require.New(t)
+
+	emu := emulator.NewCard()
+
+	_, err := run(t, emu, "", "add", testURI)
+	require.NoError(err)
+
+	_, err = run(t, emu, "", "add", "-type", "hotp", "-secret", "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", "-digits", "8", "bob")
+	require.NoError(err)
+
+	// Overwriting requires confirmation
+	_, err = run(t, emu, "n\n", "add", testURI)
+	require.ErrorIs(err, errAborted)
+
+	out, err := run(t, emu, "", "list")
+	require.NoError(err)
+	require.Contains(out, "Example:alice@example.com  TOTP")
+	require.Contains(out, "bob                        HOTP")
+
+	out, err = run(t, emu, "", "-json", "code")
+	require.NoError(err)
+
+	var codes []codeJSON
+	require.NoError(json.Unmarshal([]byte(out), &codes))
+	require.Len(codes, 2)
+	require.Equal("Example", codes[0].Issuer)
+	require.Len(codes[0].Code, 6)
+	require.NotNil(codes[0].ValidUntil)
+	require.Equal("HOTP", codes[1].Type)
+	require.Empty(codes[1].Code)
+
+	out, err = run(t, emu, "", "code", "bob")
+	require.NoError(err)
+	require.Equal("84755224\n", out)
+
+	_, err = run(t, emu, "", "rename", "bob", "Example:bob")
+	require.NoError(err)
+
+	_, err = run(t, emu, "", "delete", "Example:bob")
+	require.NoError(err)
+	require.Equal([]string{"Example:alice@example.com"}, emu.Credentials())
+
+	_, err = run(t, emu, "no\n", "reset")
+	require.ErrorIs(err, errAborted)
+
+	_, err = run(t, emu, "y\n", "reset")
+	require.NoError(err)
+	require.Empty(emu.Credentials())
+}
+
+func TestPassword(t *testing.T) {
+	require := require.New(t)
+
+	emu := emulator.NewCard()
+
+	_, err := run(t, emu, "", "add", testURI)
+	require.NoError(err)
+
+	_, err = run(t, emu, "", "password", "set", "secret")
+	require.NoError(err)
+
+	_, err = run(t, emu, "\n", "list")
+	require.ErrorIs(err, errPasswordMissing)
+
+	_, err = run(t, emu, "wrong\n", "password", "validate")
+	require.Error(err)
+
+	out, err := run(t, emu, "secret\n", "list")
+	require.NoError(err)
+	require.Contains(out, "Example:alice@example.com")
+
+	out, err = run(t, emu, "", "-json", "info")
+	require.NoError(err)
+
+	var info infoJSON
+	require.NoError(json.Unmarshal([]byte(out), &info))
+	require.True(info.PasswordProtected)
+	require.Equal("5.4.3", info.Version)
+	require.NotEmpty(info.DeviceID)
+
+	_, err = run(t, emu, "", "-password", "secret", "password", "remove")
+	require.NoError(err)
+
+	out, err = run(t, emu, "", "info")
+	require.NoError(err)
+	require.Contains(out, "Password protected:  false")
+}
+
+func TestPasswordTerminal(t *testing.T) {
+	require := require.New(t)
+
+	var stderr bytes.Buffer
+
+	a := &app{
+		stdin:  bufio.NewReader(strings.NewReader("echoed\n")),
+		stderr: &stderr,
+		readPassword: func() ([]byte, error) {
+			return []byte("secret"), nil
+		},
+	}
+
+	pw, err := a.getPassword("Password: ")
+	require.NoError(err)
+	require.Equal([]byte("secret"), pw)
+	require.Equal("Password: \n", stderr.String())
+
+	a.readPassword = func() ([]byte, error) {
+		return nil, nil
+	}
+
+	_, err = a.getPassword("Password: ")
+	require.ErrorIs(err, errPasswordMissing)
+}
+
+func TestUsage(t *testing.T) {
+	require := require.New(t)
+
+	emu := emulator.NewCard()
+
+	_, err := run(t, emu, "")
+	require.ErrorIs(err, errUsage)
+
+	_, err = run(t, emu, "", "unknown")
+	require.ErrorIs(err, errUnknownCommand)
+
+	_, err = run(t, emu, "", "rename", "a")
+	require.ErrorIs(err, errUsage)
+
+	out, err := run(t, emu, "", "readers")
+	require.NoError(err)
+	require.Equal("Yubico YubiKey OTP+FIDO+CCID 00 00\n", out)
+}
+
+func TestDevices(t *testing.T) {
+	require := require.New(t)
+
+	emu := emulator.NewCard()
+	emu.Serial = 12345678
+
+	out, err := run(t, emu, "", "-json", "devices")
+	require.NoError(err)
+
+	var devs []deviceJSON
+	require.NoError(json.Unmarshal([]byte(out), &devs))
+	require.Len(devs, 1)
+	require.EqualValues(12345678, devs[0].Serial)
+	require.Equal("5.4.3", devs[0].Version)
+
+	_, err = run(t, emu, "", "-device", "12345678", "list")
+	require.NoError(err)
+
+	_, err = run(t, emu, "", "-device", devs[0].DeviceID, "list")
+	require.NoError(err)
+
+	_, err = run(t, emu, "", "-device", "87654321", "list")
+	require.ErrorIs(err, device.ErrNotFound)
+}
+
+func TestApply(t *testing.T) {
+	require := require.New(t)
+
+	emu := emulator.NewCard()
+
+	_, err := run(t, emu, "", "add", "-secret", "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", "stale")
+	require.NoError(err)
+
+	mf := filepath.Join(t.TempDir(), "manifest.json")
+	err = os.WriteFile(mf, []byte(`{
+		"credentials": [
+			{ "name": "alice@example.com", "issuer": "Example", "secret": { "base32": "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ" } }
+		]
+	}`), 0o600)
+	require.NoError(err)
+
+	_, err = run(t, emu, "n\n", "apply", "-prune", mf)
+	require.ErrorIs(err, errAborted)
+
+	out, err := run(t, emu, "", "apply", "-prune", "-dry-run", mf)
+	require.NoError(err)
+	require.Contains(out, "delete  stale")
+	require.Contains(out, "add     Example:alice@example.com")
+
+	_, err = run(t, emu, "y\n", "apply", "-prune", mf)
+	require.NoError(err)
+
+	out, err = run(t, emu, "", "list")
+	require.NoError(err)
+	require.Contains(out, "Example:alice@example.com")
+	require.NotContains(out, "stale")
+}
@@ -8,27 +8,61 @@ import (
 	"fmt"
 )
 
+const (
+	// SteamIssuer is the issuer of credentials which are rendered as Steam Guard codes
+	SteamIssuer = "Steam"
+
+	// SteamDigits is the number of characters of a Steam Guard code
+	SteamDigits = 5
+
+	steamAlphabet = "23456789BCDFGHJKMNPQRTVWXY"
+)
+
 type Code struct {
 	Hash          []byte
 	Digits        int
 	Type          Type
 	TouchRequired bool
 	Truncated     bool
+
+	// Steam renders the code as a Steam Guard code.
+	// This requires the full HMAC response as the truncated
+	// response has already been reduced to decimal digits.
+	Steam bool
 }
 
 // OTP converts a value into a (6 or 8 digits) one-time password
 // See: RFC 4226 Section 5.3 - Generating an HOTP Value
 // https://datatracker.ietf.org/doc/html/rfc4226#section-5.3
 func (c Code) OTP() string {
-	var code uint32
-	if c.Truncated {
-		code = binary.BigEndian.Uint32(c.Hash)
-	} else {
-		hl := len(c.Hash)
-		o := c.Hash[hl-1] & 0xf
-		code = binary.BigEndian.Uint32(c.Hash[o:o+4]) & ^uint32(1<<31)
+	if c.Steam {
+		return c.SteamOTP()
 	}
 
-	s := fmt.Sprintf("%08d", code)
+	s := fmt.Sprintf("%08d", c.value())
 	return s[len(s)-c.Digits:]
 }
+
+// SteamOTP converts a value into a 5 character Steam Guard code
+func (c Code) SteamOTP() string {
+	code := c.value()
+	s := make([]byte, SteamDigits)
+
+	for i := range s {
+		s[i] = steamAlphabet[code%uint32(len(steamAlphabet))]
+		code /= uint32(len(steamAlphabet))
+	}
+
+	return string(s)
+}
+
+// value returns the dynamically truncated value
+func (c Code) value() uint32 {
+	if c.Truncated {
+		return binary.BigEndian.Uint32(c.Hash)
+	}
+
+	hl := len(c.Hash)
+	o := c.Hash[hl-1] & 0xf
+	return binary.BigEndian.Uint32(c.Hash[o:o+4]) & ^uint32(1<<31)
+}
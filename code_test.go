@@ -22,3 +22,18 @@ func TestOTP(t *testing.T) {
 		require.Equal(v.Code, c.OTP())
 	}
 }
+
+func TestSteamOTP(t *testing.T) {
+	require := require.New(t)
+
+	for i, expected := range []string{"GG5F5", "PV9M4", "B26KJ"} {
+		c := ykoath.Code{
+			Hash:   vectorsHOTP[i].Hash,
+			Digits: 6,
+			Steam:  true,
+		}
+
+		require.Equal(expected, c.OTP())
+		require.Equal(expected, c.SteamOTP())
+	}
+}
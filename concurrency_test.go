@@ -0,0 +1,110 @@
+// SPDX-FileCopyrightText: 2023 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package ykoath_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	ykoath "cunicu.li/go-ykoath/v2"
+	"cunicu.li/go-ykoath/v2/emulator"
+)
+
+func TestConcurrentUse(t *testing.T) {
+	require := require.New(t)
+
+	card, err := ykoath.NewCard(emulator.NewCard())
+	require.NoError(err)
+
+	defer card.Close()
+
+	_, err = card.Select()
+	require.NoError(err)
+
+	err = card.Put(ykoath.CredentialID{Account: "testvector"}, ykoath.HmacSha1, ykoath.Totp, 8, []byte("12345678901234567890"), false, 0)
+	require.NoError(err)
+
+	password := []byte("secret")
+
+	err = card.SetCode(password, ykoath.HmacSha1)
+	require.NoError(err)
+
+	card.PasswordProvider = func(*ykoath.Select) ([]byte, error) {
+		return password, nil
+	}
+
+	var wg sync.WaitGroup
+
+	for range 8 {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for range 20 {
+				// Each validation selects the applet again which
+				// must not interfere with the other goroutines
+				assert.NoError(t, card.Validate(password))
+
+				calcs, err := card.CalculateAll()
+				assert.NoError(t, err)
+				assert.Len(t, calcs, 1)
+
+				names, err := card.List()
+				assert.NoError(t, err)
+				assert.Len(t, names, 1)
+
+				_, _, err = card.CalculateChallengeResponse("testvector", []byte{1, 2, 3, 4})
+				assert.NoError(t, err)
+			}
+		}()
+	}
+
+	wg.Wait()
+}
+
+func TestConcurrentContext(t *testing.T) {
+	require := require.New(t)
+
+	sc := &slowCard{
+		release: make(chan struct{}),
+	}
+
+	card, err := ykoath.NewCard(sc)
+	require.NoError(err)
+
+	done := make(chan error)
+
+	go func() {
+		_, err := card.List()
+		done <- err
+	}()
+
+	require.Eventually(func() bool {
+		return sc.transmitted.Load() == 1
+	}, time.Second, time.Millisecond)
+
+	// Waiting for the other operation is aborted by the context
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	err = card.DeleteContext(ctx, "test")
+	require.ErrorIs(err, context.DeadlineExceeded)
+	require.EqualValues(1, sc.transmitted.Load())
+
+	close(sc.release)
+	require.NoError(<-done)
+
+	err = card.Delete("test")
+	require.NoError(err)
+	require.EqualValues(2, sc.transmitted.Load())
+
+	err = card.Close()
+	require.NoError(err)
+}
@@ -0,0 +1,69 @@
+// SPDX-FileCopyrightText: 2023 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package ykoath_test
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	iso "cunicu.li/go-iso7816"
+	"github.com/stretchr/testify/require"
+
+	ykoath "cunicu.li/go-ykoath/v2"
+)
+
+// slowCard is a card which only responds after it has been released
+type slowCard struct {
+	release     chan struct{}
+	transmitted atomic.Int32
+}
+
+func (c *slowCard) Transmit([]byte) ([]byte, error) {
+	c.transmitted.Add(1)
+	<-c.release
+	return []byte{0x90, 0x00}, nil
+}
+
+func (c *slowCard) BeginTransaction() error { return nil }
+func (c *slowCard) EndTransaction() error   { return nil }
+func (c *slowCard) Close() error            { return nil }
+func (c *slowCard) Base() iso.PCSCCard      { return c }
+
+func TestContext(t *testing.T) {
+	require := require.New(t)
+
+	sc := &slowCard{
+		release: make(chan struct{}),
+	}
+
+	card, err := ykoath.NewCard(sc)
+	require.NoError(err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	_, err = card.ListContext(ctx)
+	require.ErrorIs(err, context.DeadlineExceeded)
+
+	// The abandoned exchange is still pending
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+
+	err = card.DeleteContext(ctx, "test")
+	require.ErrorIs(err, context.Canceled)
+	require.EqualValues(1, sc.transmitted.Load())
+
+	// Further exchanges succeed once the card completed the abandoned one
+	close(sc.release)
+
+	names, err := card.List()
+	require.NoError(err)
+	require.Empty(names)
+	require.EqualValues(2, sc.transmitted.Load())
+
+	err = card.Close()
+	require.NoError(err)
+}
@@ -4,54 +4,134 @@
 package ykoath
 
 import (
+	"errors"
 	"fmt"
 	"regexp"
 	"strconv"
 	"strings"
 	"time"
+	"unicode/utf8"
 )
 
-var credRegex = regexp.MustCompile(`^((?P<timestep>\d+)/)?((?P<issuer>[^:]+):)?(?P<name>.+)$`)
+var ErrInvalidPeriod = errors.New("invalid period")
 
-type credential struct {
-	TimeStep time.Duration
-	Name     string
-	Issuer   string
+// MaxPeriod is the longest time-step of TOTP credentials.
+const MaxPeriod = 24 * time.Hour
+
+var credRegex = regexp.MustCompile(`^((?P<period>\d+)/)?((?P<issuer>[^:]+):)?(?P<account>.+)$`)
+
+// CredentialID identifies an OATH credential on the card.
+// It is stored on the card as "[period/][issuer:]account" where the period
+// prefix is only used for TOTP credentials with a non-default period.
+type CredentialID struct {
+	// Period is the time-step of TOTP credentials.
+	// It is zero for HOTP credentials.
+	Period time.Duration
+
+	Issuer  string
+	Account string
 }
 
-func (c credential) String() string {
-	return fmt.Sprintf("%s: %s", c.Issuer, c.Name)
+// ParseCredentialID parses the name of a credential as stored on the card.
+func ParseCredentialID(name string, typ Type) (id CredentialID, err error) {
+	return id, id.Unmarshal([]byte(name), typ)
 }
 
-func (c credential) Marshal() []byte {
+// parseName decodes the name of a credential as listed by the card.
+// Like ykman, names which can not be decoded, e.g. because they have been written
+// by another application, are used as account with the default period.
+func parseName(name []byte, t Type) CredentialID {
+	var id CredentialID
+	if err := id.Unmarshal(name, t); err != nil {
+		return CredentialID{
+			Period:  DefaultTimeStep,
+			Account: string(name),
+		}
+	}
+
+	return id
+}
+
+// IsSteam returns true if codes of the credential are rendered as Steam Guard codes.
+func (id CredentialID) IsSteam() bool {
+	return id.Issuer == SteamIssuer && id.Period != 0
+}
+
+// String returns the name of the credential as stored on the card.
+func (id CredentialID) String() string {
+	return string(id.Marshal())
+}
+
+// Marshal encodes the identifier into the name as stored on the card.
+func (id CredentialID) Marshal() []byte {
 	s := ""
 
-	if c.TimeStep != DefaultTimeStep {
-		s += fmt.Sprintf("%d/", c.TimeStep/time.Second)
+	if id.Period != 0 && id.Period != DefaultTimeStep {
+		s += fmt.Sprintf("%d/", id.Period/time.Second)
 	}
 
-	if c.Issuer != "" {
-		s += c.Issuer + ":"
+	if id.Issuer != "" {
+		s += id.Issuer + ":"
 	}
 
-	s += c.Name
+	s += id.Account
 
 	return []byte(s)
 }
 
-func (c *credential) Unmarshal(b []byte, t Type) error {
+// Truncate shortens the account and if required the issuer so that the
+// name fits into MaxNameLength bytes. The period prefix is always retained.
+// It returns true if the identifier has been shortened.
+func (id CredentialID) Truncate() (CredentialID, bool) {
+	excess := len(id.Marshal()) - MaxNameLength
+	if excess <= 0 {
+		return id, false
+	}
+
+	// Keep at least the first character of the account
+	if keep := len(id.Account) - excess; keep > 0 {
+		id.Account = truncateUTF8(id.Account, keep)
+	} else {
+		id.Account = truncateUTF8(id.Account, 1)
+		excess = len(id.Marshal()) - MaxNameLength
+		id.Issuer = truncateUTF8(id.Issuer, len(id.Issuer)-excess)
+	}
+
+	return id, true
+}
+
+// truncateUTF8 shortens s to at most n bytes without splitting a character
+func truncateUTF8(s string, n int) string {
+	if n <= 0 {
+		return ""
+	}
+
+	for n < len(s) && !utf8.RuneStart(s[n]) {
+		n--
+	}
+
+	if n > len(s) {
+		return s
+	}
+
+	return s[:n]
+}
+
+// Unmarshal decodes the name as stored on the card.
+// The type is required as HOTP credentials never carry a period prefix.
+func (id *CredentialID) Unmarshal(b []byte, t Type) error {
 	s := string(b)
 
 	if t == Hotp {
 		if parts := strings.SplitN(s, ":", 2); len(parts) > 1 {
-			c.Issuer = parts[0]
-			c.Name = parts[1]
+			id.Issuer = parts[0]
+			id.Account = parts[1]
 		} else {
-			c.Issuer = ""
-			c.Name = parts[0]
+			id.Issuer = ""
+			id.Account = parts[0]
 		}
 
-		c.TimeStep = 0
+		id.Period = 0
 
 		return nil
 	}
@@ -59,25 +139,29 @@ func (c *credential) Unmarshal(b []byte, t Type) error {
 	m := credRegex.FindStringSubmatch(s)
 	if m != nil {
 		if m[2] != "" {
-			ts, err := strconv.Atoi(m[2])
+			p, err := strconv.Atoi(m[2])
 			if err != nil {
-				return err
+				return fmt.Errorf("%w: %w", ErrInvalidPeriod, err)
+			} else if p == 0 {
+				return fmt.Errorf("%w: must not be zero", ErrInvalidPeriod)
+			} else if p > int(MaxPeriod/time.Second) {
+				return fmt.Errorf("%w: %d exceeds %d seconds", ErrInvalidPeriod, p, int(MaxPeriod/time.Second))
 			}
 
-			c.TimeStep = time.Second * time.Duration(ts)
+			id.Period = time.Second * time.Duration(p)
 		} else {
-			c.TimeStep = DefaultTimeStep
+			id.Period = DefaultTimeStep
 		}
 
-		c.Issuer = m[4]
-		c.Name = m[5]
+		id.Issuer = m[4]
+		id.Account = m[5]
 
 		return nil
 	}
 
-	c.Issuer = ""
-	c.Name = s
-	c.TimeStep = DefaultTimeStep
+	id.Issuer = ""
+	id.Account = s
+	id.Period = DefaultTimeStep
 
 	return nil
 }
@@ -4,6 +4,7 @@
 package ykoath
 
 import (
+	"strings"
 	"testing"
 	"time"
 
@@ -16,55 +17,122 @@ func TestCredential(t *testing.T) {
 	cases := []struct {
 		Data     []byte
 		Type     Type
-		Expected credential
+		Expected CredentialID
 	}{
 		{
 			Data: []byte("test"),
 			Type: Totp,
-			Expected: credential{
-				Issuer:   "",
-				Name:     "test",
-				TimeStep: DefaultTimeStep,
+			Expected: CredentialID{
+				Issuer:  "",
+				Account: "test",
+				Period:  DefaultTimeStep,
 			},
 		},
 		{
 			Data: []byte("testIssuer:testName"),
 			Type: Totp,
-			Expected: credential{
-				Issuer:   "testIssuer",
-				Name:     "testName",
-				TimeStep: DefaultTimeStep,
+			Expected: CredentialID{
+				Issuer:  "testIssuer",
+				Account: "testName",
+				Period:  DefaultTimeStep,
 			},
 		},
 		{
 			Data: []byte("45/testIssuer:testName"),
 			Type: Totp,
-			Expected: credential{
-				Issuer:   "testIssuer",
-				Name:     "testName",
-				TimeStep: 45 * time.Second,
+			Expected: CredentialID{
+				Issuer:  "testIssuer",
+				Account: "testName",
+				Period:  45 * time.Second,
 			},
 		},
 		{
 			Data: []byte("45/testName"),
 			Type: Totp,
-			Expected: credential{
-				Issuer:   "",
-				Name:     "testName",
-				TimeStep: 45 * time.Second,
+			Expected: CredentialID{
+				Issuer:  "",
+				Account: "testName",
+				Period:  45 * time.Second,
+			},
+		},
+		{
+			Data: []byte("testIssuer:testName"),
+			Type: Hotp,
+			Expected: CredentialID{
+				Issuer:  "testIssuer",
+				Account: "testName",
+			},
+		},
+		{
+			Data: []byte("45/testName"),
+			Type: Hotp,
+			Expected: CredentialID{
+				Issuer:  "",
+				Account: "45/testName",
 			},
 		},
 	}
 
 	for _, tc := range cases {
-		var cred credential
+		var id CredentialID
 
-		err := cred.Unmarshal(tc.Data, tc.Type)
+		err := id.Unmarshal(tc.Data, tc.Type)
 		assert.NoError(err)
 
-		assert.Equal(tc.Expected, cred)
+		assert.Equal(tc.Expected, id)
 
-		data := cred.Marshal()
+		data := id.Marshal()
 		assert.Equal(tc.Data, data, "Got: %s", string(data))
 	}
 }
+
+func TestCredentialInvalidPeriod(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, name := range []string{"0/test", "86401/test", "18446744073/test", "99999999999999999999/test"} {
+		var id CredentialID
+
+		err := id.Unmarshal([]byte(name), Totp)
+		assert.ErrorIs(err, ErrInvalidPeriod, name)
+	}
+
+	// Periods shorter than a second must not cause a division by zero
+	from, until := timeStep(time.Unix(59, 0), time.Millisecond)
+	assert.Equal(time.Unix(30, 0), from)
+	assert.Equal(time.Unix(60, 0), until)
+}
+
+func TestCredentialTruncate(t *testing.T) {
+	assert := assert.New(t)
+
+	id := CredentialID{
+		Issuer:  "Example",
+		Account: "alice",
+	}
+
+	tid, ok := id.Truncate()
+	assert.False(ok)
+	assert.Equal(id, tid)
+
+	// The account is shortened first without splitting multi-byte characters
+	id = CredentialID{
+		Period:  60 * time.Second,
+		Issuer:  "Example",
+		Account: strings.Repeat("ä", 40),
+	}
+
+	tid, ok = id.Truncate()
+	assert.True(ok)
+	assert.Equal("60/Example:"+strings.Repeat("ä", 26), tid.String())
+	assert.LessOrEqual(len(tid.Marshal()), MaxNameLength)
+
+	// The issuer is shortened if the account alone does not suffice
+	id = CredentialID{
+		Issuer:  strings.Repeat("i", 100),
+		Account: "alice",
+	}
+
+	tid, ok = id.Truncate()
+	assert.True(ok)
+	assert.Equal(strings.Repeat("i", 62)+":a", tid.String())
+}
@@ -4,12 +4,25 @@
 package ykoath
 
 import (
+	"context"
+
 	"cunicu.li/go-iso7816/encoding/tlv"
 )
 
 // Delete sends a "DELETE" instruction, removing one named OATH credential
 func (c *Card) Delete(name string) error {
-	_, err := c.send(insDelete, 0x00, 0x00,
+	return c.DeleteContext(context.Background(), name)
+}
+
+// DeleteContext is like Delete but aborts when the context is done
+func (c *Card) DeleteContext(ctx context.Context, name string) error {
+	if err := c.acquire(ctx); err != nil {
+		return err
+	}
+
+	defer c.release()
+
+	_, err := c.send(ctx, insDelete, 0x00, 0x00,
 		tlv.New(tagName, []byte(name)),
 	)
 	return err
@@ -0,0 +1,235 @@
+// SPDX-FileCopyrightText: 2023 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+// Package device discovers tokens with the YKOATH applet among all PC/SC readers.
+package device
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	iso "cunicu.li/go-iso7816"
+	yk "cunicu.li/go-iso7816/devices/yubikey"
+	"cunicu.li/go-iso7816/drivers/pcsc"
+	"cunicu.li/go-iso7816/filter"
+	"github.com/ebfe/scard"
+
+	ykoath "cunicu.li/go-ykoath/v2"
+)
+
+var (
+	ErrNotFound  = errors.New("no matching device found")
+	ErrAmbiguous = errors.New("identifier matches multiple devices")
+)
+
+// Info describes a token with the YKOATH applet.
+type Info struct {
+	// Reader is the name of the PC/SC reader.
+	Reader string
+
+	// Version is the firmware version reported by the applet.
+	Version ykoath.Version
+
+	// DeviceID is the identifier derived from the salt of the applet.
+	// See ykoath.Select.DeviceID.
+	DeviceID string
+
+	// Name is the raw name (salt) returned by the SELECT instruction.
+	Name []byte
+
+	// Serial is the serial number of the token or zero if it is not available.
+	Serial uint32
+}
+
+// Matches checks whether the identifier refers to this device.
+// The identifier can be either the serial number, the device ID,
+// the hex-encoded name of the applet or the name of the reader.
+func (i *Info) Matches(id string) bool {
+	switch {
+	case id == "":
+		return false
+
+	case id == i.Reader, id == i.DeviceID:
+		return true
+
+	case i.Serial != 0 && id == strconv.FormatUint(uint64(i.Serial), 10):
+		return true
+
+	case len(i.Name) > 0 && strings.EqualFold(id, hex.EncodeToString(i.Name)):
+		return true
+	}
+
+	return false
+}
+
+func (i *Info) String() string {
+	s := fmt.Sprintf("%s (version %s, device ID %s", i.Reader, i.Version, i.DeviceID)
+	if i.Serial != 0 {
+		s += fmt.Sprintf(", serial %d", i.Serial)
+	}
+
+	return s + ")"
+}
+
+// Probe queries the information of a token.
+// It returns an error if the token has no YKOATH applet.
+func Probe(card iso.PCSCCard) (*Info, error) {
+	info := &Info{}
+
+	if rc, ok := card.Base().(iso.ReaderCard); ok {
+		info.Reader = rc.Reader()
+	}
+
+	// The serial number is only available via the OTP applet
+	isoCard := iso.NewCard(card)
+
+	tx, err := isoCard.NewTransaction()
+	if err != nil {
+		return nil, fmt.Errorf("failed to initiate transaction: %w", err)
+	}
+
+	if sno, ok := yk.Metadata(isoCard)["serial"].(uint32); ok {
+		info.Serial = sno
+	}
+
+	if err := tx.EndTransaction(); err != nil {
+		return nil, fmt.Errorf("failed to end transaction: %w", err)
+	}
+
+	c, err := ykoath.NewCard(card)
+	if err != nil {
+		return nil, err
+	}
+
+	defer c.Close()
+
+	sel, err := c.Select()
+	if err != nil {
+		return nil, fmt.Errorf("failed to select applet: %w", err)
+	}
+
+	info.Version = sel.Version
+	info.DeviceID = sel.DeviceID()
+	info.Name = sel.Name
+
+	return info, nil
+}
+
+// HasID returns a filter which matches tokens identified by id.
+// See Info.Matches for the supported identifiers.
+func HasID(id string) filter.Filter {
+	return func(card iso.PCSCCard) (bool, error) {
+		if card == nil {
+			return false, filter.ErrOpen
+		}
+
+		info, err := Probe(card)
+		if err != nil {
+			return false, nil //nolint:nilerr
+		}
+
+		return info.Matches(id), nil
+	}
+}
+
+// List returns information about all tokens with the YKOATH applet
+// sorted by the names of their readers.
+func List(ctx *scard.Context) (infos []*Info, err error) {
+	err = each(ctx, func(_ *iso.Card, info *Info) bool {
+		infos = append(infos, info)
+		return false
+	})
+
+	return infos, err
+}
+
+// Open connects to the token which is identified by id.
+// See Info.Matches for the supported identifiers.
+// An empty identifier opens the first token if only one is connected.
+//
+// Closing the returned Card ends its session but keeps the connection to the
+// reader open. Use card.Card.Close() to disconnect afterwards.
+func Open(ctx *scard.Context, id string) (*ykoath.Card, *Info, error) {
+	var (
+		cards []*iso.Card
+		infos []*Info
+	)
+
+	if err := each(ctx, func(card *iso.Card, info *Info) bool {
+		if id != "" && !info.Matches(id) {
+			return false
+		}
+
+		cards = append(cards, card)
+		infos = append(infos, info)
+
+		return true
+	}); err != nil {
+		return nil, nil, err
+	}
+
+	closeAll := func() {
+		for _, card := range cards {
+			card.Close() //nolint:errcheck
+		}
+	}
+
+	switch len(cards) {
+	case 0:
+		if id == "" {
+			return nil, nil, ErrNotFound
+		}
+
+		return nil, nil, fmt.Errorf("%w: %s", ErrNotFound, id)
+
+	case 1:
+
+	default:
+		closeAll()
+
+		return nil, nil, fmt.Errorf("%w: %d devices", ErrAmbiguous, len(cards))
+	}
+
+	c, err := ykoath.NewCard(cards[0])
+	if err != nil {
+		closeAll()
+
+		return nil, nil, err
+	}
+
+	return c, infos[0], nil
+}
+
+// each probes the cards in all readers and invokes cb for each token with the YKOATH applet.
+// The card remains connected if cb returns true.
+func each(ctx *scard.Context, cb func(card *iso.Card, info *Info) bool) error {
+	readers, err := ctx.ListReaders()
+	if err != nil {
+		if errors.Is(err, scard.ErrNoReadersAvailable) {
+			return nil
+		}
+
+		return fmt.Errorf("failed to list readers: %w", err)
+	}
+
+	slices.Sort(readers)
+
+	for _, reader := range readers {
+		card, err := pcsc.NewCard(ctx, reader, false)
+		if err != nil {
+			// The reader may be empty or in use by another application
+			continue
+		}
+
+		info, err := Probe(card)
+		if err != nil || !cb(card, info) {
+			card.Close() //nolint:errcheck
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,73 @@
+// SPDX-FileCopyrightText: 2023 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package device_test
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"cunicu.li/go-iso7816/filter"
+	"github.com/stretchr/testify/require"
+
+	ykoath "cunicu.li/go-ykoath/v2"
+	"cunicu.li/go-ykoath/v2/device"
+	"cunicu.li/go-ykoath/v2/emulator"
+)
+
+func TestProbe(t *testing.T) {
+	require := require.New(t)
+
+	emu := emulator.NewCard()
+	emu.Serial = 12345678
+
+	info, err := device.Probe(emu)
+	require.NoError(err)
+	require.Equal(ykoath.Version{Major: 5, Minor: 4, Patch: 3}, info.Version)
+	require.EqualValues(12345678, info.Serial)
+	require.Len(info.Name, 8)
+	require.NotEmpty(info.DeviceID)
+
+	// The card can be used after probing
+	card, err := ykoath.NewCard(emu)
+	require.NoError(err)
+
+	defer card.Close()
+
+	sel, err := card.Select()
+	require.NoError(err)
+	require.Equal(info.DeviceID, sel.DeviceID())
+
+	require.True(info.Matches("12345678"))
+	require.True(info.Matches(info.DeviceID))
+	require.True(info.Matches(hex.EncodeToString(info.Name)))
+	require.False(info.Matches("87654321"))
+	require.False(info.Matches(""))
+}
+
+func TestProbeWithoutSerial(t *testing.T) {
+	require := require.New(t)
+
+	info, err := device.Probe(emulator.NewCard())
+	require.NoError(err)
+	require.Zero(info.Serial)
+	require.False(info.Matches("0"))
+}
+
+func TestHasID(t *testing.T) {
+	require := require.New(t)
+
+	emu := emulator.NewCard()
+	emu.Serial = 12345678
+
+	_, err := device.HasID("12345678")(nil)
+	require.ErrorIs(err, filter.ErrOpen)
+
+	match, err := device.HasID("12345678")(emu)
+	require.NoError(err)
+	require.True(match)
+
+	match, err = device.HasID("87654321")(emu)
+	require.NoError(err)
+	require.False(match)
+}
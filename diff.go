@@ -0,0 +1,181 @@
+// SPDX-FileCopyrightText: 2023 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package ykoath
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Mismatch is a pair of credentials with the same name but a
+// different algorithm or type.
+// For Sync, the target may also be the credential provided by the SecretSource.
+type Mismatch struct {
+	Source *Name
+	Target *Name
+}
+
+// Diff is the result of comparing the credentials of two cards.
+type Diff struct {
+	// Missing lists the credentials which are only present on the source card.
+	Missing []*Name
+
+	// Extra lists the credentials which are only present on the target card.
+	Extra []*Name
+
+	// Mismatched lists credentials which are present on both cards
+	// but differ in their algorithm or type.
+	// Sync also lists missing credentials whose secret source disagrees with the source card.
+	Mismatched []Mismatch
+
+	// Copied lists the credentials which have been copied to the target card by Sync.
+	Copied []*Name
+}
+
+// Empty returns true if both cards hold the same credentials.
+func (d *Diff) Empty() bool {
+	return len(d.Missing) == 0 && len(d.Extra) == 0 && len(d.Mismatched) == 0
+}
+
+// String returns a human readable representation of the differences
+func (d *Diff) String() string {
+	var sb strings.Builder
+
+	for _, n := range d.Copied {
+		fmt.Fprintf(&sb, "copied      %s\n", n.Name)
+	}
+
+	for _, n := range d.Missing {
+		fmt.Fprintf(&sb, "missing     %s\n", n.Name)
+	}
+
+	for _, n := range d.Extra {
+		fmt.Fprintf(&sb, "extra       %s\n", n.Name)
+	}
+
+	for _, m := range d.Mismatched {
+		fmt.Fprintf(&sb, "mismatched  %s (%s %s != %s %s)\n", m.Source.Name,
+			m.Source.Type, m.Source.Algorithm, m.Target.Type, m.Target.Algorithm)
+	}
+
+	return sb.String()
+}
+
+// Compare compares the credentials listed on a source and a target card.
+// Credentials are matched by their name.
+func Compare(source, target []*Name) *Diff {
+	d := &Diff{}
+
+	targets := map[string]*Name{}
+	for _, t := range target {
+		targets[t.Name] = t
+	}
+
+	sources := map[string]bool{}
+
+	for _, s := range source {
+		sources[s.Name] = true
+
+		t, ok := targets[s.Name]
+		switch {
+		case !ok:
+			d.Missing = append(d.Missing, s)
+
+		case s.Algorithm != t.Algorithm || s.Type != t.Type:
+			d.Mismatched = append(d.Mismatched, Mismatch{
+				Source: s,
+				Target: t,
+			})
+		}
+	}
+
+	for _, t := range target {
+		if !sources[t.Name] {
+			d.Extra = append(d.Extra, t)
+		}
+	}
+
+	return d
+}
+
+// CompareCards lists the credentials of both cards and compares them.
+func CompareCards(ctx context.Context, source, target *Card) (*Diff, error) {
+	sourceNames, err := source.ListContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list source credentials: %w", err)
+	}
+
+	targetNames, err := target.ListContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list target credentials: %w", err)
+	}
+
+	return Compare(sourceNames, targetNames), nil
+}
+
+// SecretSource provides the complete credential including its secret
+// for a credential listed on the source card, e.g. from a backup.
+// It returns false if the secret is not available.
+type SecretSource func(n *Name) (*CredentialData, bool)
+
+// SecretsFrom returns a SecretSource which looks up credentials by their name.
+func SecretsFrom(ds []CredentialData) SecretSource {
+	byName := map[string]*CredentialData{}
+	for i := range ds {
+		byName[ds[i].ID.String()] = &ds[i]
+	}
+
+	return func(n *Name) (*CredentialData, bool) {
+		d, ok := byName[n.Name]
+		return d, ok
+	}
+}
+
+// Sync compares both cards and copies the credentials which are missing on
+// the target card from the secret source as secrets can not be read from a card.
+// The returned Diff lists the copied credentials as well as the remaining differences.
+// Mismatched and extra credentials are left untouched. Credentials whose algorithm
+// or type from the secret source differs from the source card are not copied
+// but listed as mismatched.
+func Sync(ctx context.Context, source, target *Card, secrets SecretSource) (*Diff, error) {
+	d, err := CompareCards(ctx, source, target)
+	if err != nil {
+		return nil, err
+	}
+
+	missing := d.Missing
+	d.Missing = nil
+
+	for i, n := range missing {
+		data, ok := secrets(n)
+		if !ok {
+			d.Missing = append(d.Missing, n)
+			continue
+		}
+
+		if data.Algorithm != n.Algorithm || data.Type != n.Type {
+			d.Mismatched = append(d.Mismatched, Mismatch{
+				Source: n,
+				Target: &Name{
+					Algorithm: data.Algorithm,
+					Type:      data.Type,
+					Name:      n.Name,
+					ID:        data.ID,
+				},
+			})
+
+			continue
+		}
+
+		if err := target.PutCredentialContext(ctx, data); err != nil {
+			d.Missing = append(d.Missing, missing[i:]...)
+			return d, fmt.Errorf("failed to copy %s: %w", n.Name, err)
+		}
+
+		d.Copied = append(d.Copied, n)
+	}
+
+	return d, nil
+}
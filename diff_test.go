@@ -0,0 +1,112 @@
+// SPDX-FileCopyrightText: 2023 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package ykoath_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	ykoath "cunicu.li/go-ykoath/v2"
+	"cunicu.li/go-ykoath/v2/emulator"
+)
+
+func TestSync(t *testing.T) {
+	withEmulator(t, emulator.NewCard(), nil, func(t *testing.T, source *ykoath.Card) {
+		withEmulator(t, emulator.NewCard(), nil, func(t *testing.T, target *ykoath.Card) {
+			testSync(t, source, target)
+		})
+	})
+}
+
+func testSync(t *testing.T, source, target *ykoath.Card) {
+	require := require.New(t)
+
+	secret := []byte("12345678901234567890")
+
+	backup := []ykoath.CredentialData{
+		{
+			ID:        ykoath.CredentialID{Issuer: "Example", Account: "alice", Period: ykoath.DefaultTimeStep},
+			Algorithm: ykoath.HmacSha1,
+			Type:      ykoath.Totp,
+			Digits:    6,
+			Secret:    secret,
+		},
+		{
+			ID:        ykoath.CredentialID{Account: "bob"},
+			Algorithm: ykoath.HmacSha256,
+			Type:      ykoath.Hotp,
+			Digits:    8,
+			Secret:    secret,
+			Counter:   42,
+		},
+		{
+			ID:        ykoath.CredentialID{Account: "carol", Period: ykoath.DefaultTimeStep},
+			Algorithm: ykoath.HmacSha1,
+			Type:      ykoath.Totp,
+			Digits:    6,
+			Secret:    secret,
+		},
+	}
+
+	for i := range backup {
+		err := source.PutCredential(&backup[i])
+		require.NoError(err)
+	}
+
+	err := source.Put(ykoath.CredentialID{Account: "dave"}, ykoath.HmacSha1, ykoath.Totp, 6, secret, false, 0)
+	require.NoError(err)
+
+	// The backup disagrees with the source about the algorithm of frank
+	err = source.Put(ykoath.CredentialID{Account: "frank"}, ykoath.HmacSha1, ykoath.Totp, 6, secret, false, 0)
+	require.NoError(err)
+
+	backup = append(backup, ykoath.CredentialData{
+		ID:        ykoath.CredentialID{Account: "frank", Period: ykoath.DefaultTimeStep},
+		Algorithm: ykoath.HmacSha512,
+		Type:      ykoath.Totp,
+		Digits:    6,
+		Secret:    secret,
+	})
+
+	// The target holds a credential with a different algorithm and one which is unknown to the source
+	err = target.Put(ykoath.CredentialID{Account: "carol"}, ykoath.HmacSha256, ykoath.Totp, 6, secret, false, 0)
+	require.NoError(err)
+
+	err = target.Put(ykoath.CredentialID{Account: "erin"}, ykoath.HmacSha1, ykoath.Totp, 6, secret, false, 0)
+	require.NoError(err)
+
+	d, err := ykoath.CompareCards(context.Background(), source, target)
+	require.NoError(err)
+	require.False(d.Empty())
+	require.Equal(`missing     Example:alice
+missing     bob
+missing     dave
+missing     frank
+extra       erin
+mismatched  carol (TOTP HMAC-SHA1 != TOTP HMAC-SHA256)
+`, d.String())
+
+	// Only credentials with a known secret are copied
+	d, err = ykoath.Sync(context.Background(), source, target, ykoath.SecretsFrom(backup))
+	require.NoError(err)
+	require.Len(d.Copied, 2)
+	require.Len(d.Missing, 1)
+	require.Equal("dave", d.Missing[0].Name)
+	require.Len(d.Mismatched, 2)
+	require.Equal("frank", d.Mismatched[1].Source.Name)
+	require.Equal(ykoath.HmacSha512, d.Mismatched[1].Target.Algorithm)
+
+	code, err := target.Calculate("bob")
+	require.NoError(err)
+	require.Len(code, 8)
+
+	// Credentials with a mismatching backup are not written
+	d, err = ykoath.CompareCards(context.Background(), source, target)
+	require.NoError(err)
+	require.Len(d.Missing, 2)
+	require.Len(d.Extra, 1)
+	require.Len(d.Mismatched, 1)
+}
@@ -0,0 +1,603 @@
+// SPDX-FileCopyrightText: 2023 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package emulator
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"encoding/binary"
+	"errors"
+	"slices"
+
+	iso "cunicu.li/go-iso7816"
+	"cunicu.li/go-iso7816/encoding/tlv"
+
+	ykoath "cunicu.li/go-ykoath/v2"
+)
+
+// Instruction bytes for commands
+const (
+	insPut           iso.Instruction = 0x01
+	insDelete        iso.Instruction = 0x02
+	insSetCode       iso.Instruction = 0x03
+	insReset         iso.Instruction = 0x04
+	insRename        iso.Instruction = 0x05
+	insList          iso.Instruction = 0xA1
+	insCalculate     iso.Instruction = 0xA2
+	insValidate      iso.Instruction = 0xA3
+	insCalculateAll  iso.Instruction = 0xA4
+	insSendRemaining iso.Instruction = 0xA5
+)
+
+// Instruction bytes and slots of the OTP applet
+const (
+	insOTP           iso.Instruction = 0x01
+	insOTPReadStatus iso.Instruction = 0x03
+
+	otpSlotSerial = 0x10
+)
+
+// TLV tags for credential data
+const (
+	tagName      tlv.Tag = 0x71
+	tagNameList  tlv.Tag = 0x72
+	tagKey       tlv.Tag = 0x73
+	tagChallenge tlv.Tag = 0x74
+	tagResponse  tlv.Tag = 0x75
+	tagTruncated tlv.Tag = 0x76
+	tagHOTP      tlv.Tag = 0x77
+	tagProperty  tlv.Tag = 0x78
+	tagVersion   tlv.Tag = 0x79
+	tagImf       tlv.Tag = 0x7A
+	tagAlgorithm tlv.Tag = 0x7B
+	tagTouch     tlv.Tag = 0x7C
+)
+
+const propertyRequireTouch = 0x02
+
+var (
+	errAuthRequired        = ykoath.ErrAuthRequired
+	errNoSpace             = ykoath.ErrNoSpace
+	errNoSuchObject        = ykoath.ErrNoSuchObject
+	errWrongSyntax         = ykoath.ErrWrongSyntax
+	errWrongLength         = ykoath.Error(iso.ErrWrongLength)
+	errIncorrectParams     = ykoath.Error(iso.ErrIncorrectParams)
+	errUnsupportedIns      = ykoath.Error(iso.ErrUnsupportedInstruction)
+	errFileOrAppNotFound   = ykoath.Error(iso.ErrFileOrAppNotFound)
+	errConditionsNotSatisf = ykoath.Error(iso.ErrConditionsOfUseNotSatisfied)
+)
+
+var errInvalidLength = errors.New("invalid length")
+
+func (c *Card) handle(cmd *iso.CAPDU) ([]byte, error) {
+	if cmd.Ins == iso.InsSelect && cmd.P1 == 0x04 {
+		return c.handleSelect(cmd)
+	}
+
+	if c.selectedOTP {
+		return c.handleOTP(cmd)
+	} else if !c.selected {
+		return nil, errUnsupportedIns
+	}
+
+	switch cmd.Ins {
+	case insSendRemaining:
+		return c.handleSendRemaining()
+
+	case insReset:
+		return c.handleReset(cmd)
+
+	case insValidate:
+		return c.handleValidate(cmd)
+	}
+
+	if c.accessKey != nil && !c.authenticated {
+		return nil, errAuthRequired
+	}
+
+	switch cmd.Ins {
+	case insPut:
+		return c.handlePut(cmd)
+
+	case insDelete:
+		return c.handleDelete(cmd)
+
+	case insSetCode:
+		return c.handleSetCode(cmd)
+
+	case insRename:
+		return c.handleRename(cmd)
+
+	case insList:
+		return c.handleList()
+
+	case insCalculate:
+		return c.handleCalculate(cmd)
+
+	case insCalculateAll:
+		return c.handleCalculateAll(cmd)
+
+	default:
+		return nil, errUnsupportedIns
+	}
+}
+
+func (c *Card) handleSelect(cmd *iso.CAPDU) ([]byte, error) {
+	c.selectedOTP = false
+
+	if c.Serial != 0 && bytes.Equal(cmd.Data, iso.AidYubicoOTP) {
+		c.selected = false
+		c.selectedOTP = true
+
+		return c.otpStatus(), nil
+	} else if !bytes.Equal(cmd.Data, iso.AidYubicoOATH) {
+		c.selected = false
+		return nil, errFileOrAppNotFound
+	}
+
+	version, err := c.Version.MarshalBinary()
+	if err != nil {
+		return nil, errConditionsNotSatisf
+	}
+
+	c.powerCycle()
+	c.selected = true
+
+	tvs := []tlv.TagValue{
+		tlv.New(tagVersion, version),
+		tlv.New(tagName, c.salt),
+	}
+
+	if c.accessKey != nil {
+		c.challenge = c.random(8)
+
+		tvs = append(tvs,
+			tlv.New(tagChallenge, c.challenge),
+			tlv.New(tagAlgorithm, []byte{byte(c.algorithm)}),
+		)
+	}
+
+	return tlv.EncodeSimple(tvs...)
+}
+
+func (c *Card) handleSendRemaining() ([]byte, error) {
+	if c.remaining == nil {
+		return nil, errWrongSyntax
+	}
+
+	data := c.remaining
+	c.remaining = nil
+
+	return data, nil
+}
+
+func (c *Card) handleReset(cmd *iso.CAPDU) ([]byte, error) {
+	if cmd.P1 != 0xde || cmd.P2 != 0xad {
+		return nil, errIncorrectParams
+	}
+
+	c.reset()
+	c.selected = true
+
+	return nil, nil
+}
+
+func (c *Card) handlePut(cmd *iso.CAPDU) ([]byte, error) {
+	tvs, err := decode(cmd.Data)
+	if err != nil {
+		return nil, errWrongSyntax
+	}
+
+	name, ok := get(tvs, tagName)
+	if !ok || len(name) == 0 || len(name) > maxNameLength {
+		return nil, errWrongSyntax
+	}
+
+	key, ok := get(tvs, tagKey)
+	if !ok || len(key) < 2 {
+		return nil, errWrongSyntax
+	}
+
+	cred := &credential{
+		name:      slices.Clone(name),
+		algorithm: ykoath.Algorithm(key[0] & 0x0f),
+		typ:       ykoath.Type(key[0] & 0xf0),
+		digits:    key[1],
+		key:       slices.Clone(key[2:]),
+	}
+
+	switch cred.algorithm {
+	case ykoath.HmacSha1, ykoath.HmacSha256:
+	case ykoath.HmacSha512:
+		if !c.supports(ykoath.FeatureHmacSha512) {
+			return nil, errWrongSyntax
+		}
+	default:
+		return nil, errWrongSyntax
+	}
+
+	if cred.typ != ykoath.Hotp && cred.typ != ykoath.Totp {
+		return nil, errWrongSyntax
+	}
+
+	if prop, ok := get(tvs, tagProperty); ok {
+		if len(prop) != 1 {
+			return nil, errWrongSyntax
+		}
+
+		cred.touch = prop[0]&propertyRequireTouch != 0
+		if cred.touch && !c.supports(ykoath.FeatureTouch) {
+			return nil, errWrongSyntax
+		}
+	}
+
+	if imf, ok := get(tvs, tagImf); ok {
+		if len(imf) != 4 || cred.typ != ykoath.Hotp {
+			return nil, errWrongSyntax
+		}
+
+		cred.counter = binary.BigEndian.Uint32(imf)
+	}
+
+	if idx := c.index(cred.name); idx >= 0 {
+		c.credentials[idx] = cred
+	} else if len(c.credentials) >= c.MaxCredentials {
+		return nil, errNoSpace
+	} else {
+		c.credentials = append(c.credentials, cred)
+	}
+
+	return nil, nil
+}
+
+func (c *Card) handleDelete(cmd *iso.CAPDU) ([]byte, error) {
+	tvs, err := decode(cmd.Data)
+	if err != nil {
+		return nil, errWrongSyntax
+	}
+
+	name, ok := get(tvs, tagName)
+	if !ok {
+		return nil, errWrongSyntax
+	}
+
+	idx := c.index(name)
+	if idx < 0 {
+		return nil, errNoSuchObject
+	}
+
+	c.credentials = slices.Delete(c.credentials, idx, idx+1)
+
+	return nil, nil
+}
+
+func (c *Card) handleRename(cmd *iso.CAPDU) ([]byte, error) {
+	if !c.supports(ykoath.FeatureRename) {
+		return nil, errUnsupportedIns
+	}
+
+	tvs, err := decode(cmd.Data)
+	if err != nil {
+		return nil, errWrongSyntax
+	}
+
+	var names [][]byte
+	for _, tv := range tvs {
+		if tv.Tag == tagName {
+			names = append(names, tv.Value)
+		}
+	}
+
+	if len(names) != 2 {
+		return nil, errWrongSyntax
+	}
+
+	oldName, newName := names[0], names[1]
+	if len(newName) == 0 || len(newName) > maxNameLength {
+		return nil, errWrongSyntax
+	}
+
+	cred := c.find(oldName)
+	if cred == nil {
+		return nil, errNoSuchObject
+	}
+
+	if c.find(newName) != nil {
+		return nil, errWrongSyntax
+	}
+
+	cred.name = slices.Clone(newName)
+
+	return nil, nil
+}
+
+func (c *Card) handleSetCode(cmd *iso.CAPDU) ([]byte, error) {
+	tvs, err := decode(cmd.Data)
+	if err != nil {
+		return nil, errWrongSyntax
+	}
+
+	key, ok := get(tvs, tagKey)
+	if !ok {
+		return nil, errWrongSyntax
+	}
+
+	// An empty key removes the access code
+	if len(key) == 0 {
+		c.accessKey = nil
+		c.algorithm = 0
+
+		return nil, nil
+	}
+
+	alg := ykoath.Algorithm(key[0])
+	if alg.Hash() == nil {
+		return nil, errWrongSyntax
+	}
+
+	challenge, ok := get(tvs, tagChallenge)
+	if !ok {
+		return nil, errWrongSyntax
+	}
+
+	response, ok := get(tvs, tagResponse)
+	if !ok {
+		return nil, errWrongSyntax
+	}
+
+	if !hmac.Equal(response, mac(alg, key[1:], challenge)) {
+		return nil, errWrongSyntax
+	}
+
+	c.accessKey = slices.Clone(key[1:])
+	c.algorithm = alg
+	c.authenticated = true
+
+	return nil, nil
+}
+
+func (c *Card) handleValidate(cmd *iso.CAPDU) ([]byte, error) {
+	if c.accessKey == nil || c.challenge == nil {
+		return nil, errNoSuchObject
+	}
+
+	tvs, err := decode(cmd.Data)
+	if err != nil {
+		return nil, errWrongSyntax
+	}
+
+	response, ok := get(tvs, tagResponse)
+	if !ok {
+		return nil, errWrongSyntax
+	}
+
+	challenge, ok := get(tvs, tagChallenge)
+	if !ok {
+		return nil, errWrongSyntax
+	}
+
+	expected := mac(c.algorithm, c.accessKey, c.challenge)
+	c.challenge = nil
+
+	if !hmac.Equal(response, expected) {
+		return nil, errWrongSyntax
+	}
+
+	c.authenticated = true
+
+	return tlv.EncodeSimple(
+		tlv.New(tagResponse, mac(c.algorithm, c.accessKey, challenge)),
+	)
+}
+
+func (c *Card) handleList() ([]byte, error) {
+	var tvs []tlv.TagValue
+
+	for _, cred := range c.credentials {
+		tvs = append(tvs, tlv.New(tagNameList, []byte{byte(cred.algorithm) | byte(cred.typ)}, cred.name))
+	}
+
+	return tlv.EncodeSimple(tvs...)
+}
+
+func (c *Card) handleCalculate(cmd *iso.CAPDU) ([]byte, error) {
+	tvs, err := decode(cmd.Data)
+	if err != nil {
+		return nil, errWrongSyntax
+	}
+
+	name, ok := get(tvs, tagName)
+	if !ok {
+		return nil, errWrongSyntax
+	}
+
+	challenge, _ := get(tvs, tagChallenge)
+
+	cred := c.find(name)
+	if cred == nil {
+		return nil, errNoSuchObject
+	}
+
+	if cred.touch && c.Touch != nil && !c.Touch(string(cred.name)) {
+		return nil, errConditionsNotSatisf
+	}
+
+	if cred.typ == ykoath.Hotp {
+		challenge = binary.BigEndian.AppendUint64(nil, uint64(cred.counter))
+		cred.counter++
+	}
+
+	return tlv.EncodeSimple(cred.calculate(challenge, cmd.P2 == 0x01))
+}
+
+func (c *Card) handleCalculateAll(cmd *iso.CAPDU) ([]byte, error) {
+	tvs, err := decode(cmd.Data)
+	if err != nil {
+		return nil, errWrongSyntax
+	}
+
+	challenge, ok := get(tvs, tagChallenge)
+	if !ok {
+		return nil, errWrongSyntax
+	}
+
+	var resp []tlv.TagValue
+
+	for _, cred := range c.credentials {
+		resp = append(resp, tlv.New(tagName, cred.name))
+
+		switch {
+		case cred.typ == ykoath.Hotp:
+			resp = append(resp, tlv.New(tagHOTP, []byte{cred.digits}))
+
+		case cred.touch:
+			resp = append(resp, tlv.New(tagTouch, []byte{cred.digits}))
+
+		default:
+			resp = append(resp, cred.calculate(challenge, cmd.P2 == 0x01))
+		}
+	}
+
+	return tlv.EncodeSimple(resp...)
+}
+
+func (c *Card) supports(f ykoath.Feature) bool {
+	return c.Version.AtLeast(f.Version())
+}
+
+func (c *Card) index(name []byte) int {
+	return slices.IndexFunc(c.credentials, func(cred *credential) bool {
+		return bytes.Equal(cred.name, name)
+	})
+}
+
+func (c *Card) find(name []byte) *credential {
+	if idx := c.index(name); idx >= 0 {
+		return c.credentials[idx]
+	}
+
+	return nil
+}
+
+func (cred *credential) calculate(challenge []byte, truncate bool) tlv.TagValue {
+	sum := mac(cred.algorithm, cred.key, challenge)
+
+	if !truncate {
+		return tlv.New(tagResponse, []byte{cred.digits}, sum)
+	}
+
+	// See: RFC 4226 Section 5.3 - Generating an HOTP Value
+	o := sum[len(sum)-1] & 0xf
+	code := binary.BigEndian.Uint32(sum[o:o+4]) & ^uint32(1<<31)
+
+	// The applet already reduces the code to the requested number of digits
+	mod := uint32(1)
+	for range cred.digits {
+		mod *= 10
+	}
+
+	code %= mod
+
+	return tlv.New(tagTruncated, []byte{cred.digits}, binary.BigEndian.AppendUint32(nil, code))
+}
+
+func mac(alg ykoath.Algorithm, key, data []byte) []byte {
+	h := hmac.New(alg.Hash(), key)
+	h.Write(data)
+	return h.Sum(nil)
+}
+
+func parseCAPDU(b []byte) (*iso.CAPDU, error) {
+	if len(b) < iso.LenHeader {
+		return nil, errInvalidLength
+	}
+
+	cmd := &iso.CAPDU{
+		Cla: b[0],
+		Ins: iso.Instruction(b[1]),
+		P1:  b[2],
+		P2:  b[3],
+	}
+
+	b = b[iso.LenHeader:]
+
+	switch {
+	case len(b) <= 1: // Case 1 & 2
+		return cmd, nil
+
+	case b[0] == 0 && len(b) >= 3: // Extended length
+		lc := int(binary.BigEndian.Uint16(b[1:3]))
+		if len(b) < 3+lc {
+			return nil, errInvalidLength
+		}
+
+		cmd.Data = b[3 : 3+lc]
+
+	default:
+		lc := int(b[0])
+		if len(b) < 1+lc {
+			return nil, errInvalidLength
+		}
+
+		cmd.Data = b[1 : 1+lc]
+	}
+
+	return cmd, nil
+}
+
+// decode decodes the simple TLV encoded command data.
+// In contrast to tlv.DecodeSimple(), it handles the property tag which is not followed by a length.
+func decode(b []byte) (tvs []tlv.TagValue, err error) {
+	for len(b) > 0 {
+		if len(b) < 2 {
+			return nil, errInvalidLength
+		}
+
+		tag := tlv.Tag(b[0])
+		if tag == tagProperty {
+			tvs = append(tvs, tlv.New(tag, b[1:2]))
+			b = b[2:]
+			continue
+		}
+
+		l := int(b[1])
+		if len(b) < 2+l {
+			return nil, errInvalidLength
+		}
+
+		tvs = append(tvs, tlv.New(tag, b[2:2+l]))
+		b = b[2+l:]
+	}
+
+	return tvs, nil
+}
+
+func get(tvs []tlv.TagValue, tag tlv.Tag) ([]byte, bool) {
+	for _, tv := range tvs {
+		if tv.Tag == tag {
+			return tv.Value, true
+		}
+	}
+
+	return nil, false
+}
+
+// handleOTP emulates the parts of the OTP applet which
+// are required to read the status and serial number.
+func (c *Card) handleOTP(cmd *iso.CAPDU) ([]byte, error) {
+	switch {
+	case cmd.Ins == insOTPReadStatus:
+		return c.otpStatus(), nil
+
+	case cmd.Ins == insOTP && cmd.P1 == otpSlotSerial:
+		return binary.BigEndian.AppendUint32(nil, c.Serial), nil
+
+	default:
+		return nil, errUnsupportedIns
+	}
+}
+
+func (c *Card) otpStatus() []byte {
+	return []byte{byte(c.Version.Major), byte(c.Version.Minor), byte(c.Version.Patch), 0, 0, 0} //nolint:gosec
+}
@@ -0,0 +1,274 @@
+// SPDX-FileCopyrightText: 2023 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+// Package emulator implements a software emulation of the YKOATH applet
+// which can be used as a drop-in replacement for a real YubiKey in tests.
+package emulator
+
+import (
+	"crypto/rand"
+	"errors"
+	"io"
+	"slices"
+	"sync"
+
+	iso "cunicu.li/go-iso7816"
+
+	ykoath "cunicu.li/go-ykoath/v2"
+)
+
+const (
+	// DefaultMaxCredentials is the number of credentials which
+	// can be stored by YubiKey 5 series tokens prior to firmware 5.7.
+	DefaultMaxCredentials = 32
+
+	// maxResponseLength is the maximum number of bytes returned in a single
+	// response before the applet requests the use of SEND REMAINING.
+	maxResponseLength = 0xff
+
+	maxNameLength = 64
+)
+
+var (
+	ErrRemoved           = errors.New("card removed")
+	ErrNoTransaction     = errors.New("no transaction in progress")
+	ErrTransactionActive = errors.New("transaction already in progress")
+)
+
+var _ iso.PCSCCard = (*Card)(nil)
+
+type credential struct {
+	name      []byte
+	algorithm ykoath.Algorithm
+	typ       ykoath.Type
+	digits    byte
+	key       []byte
+	touch     bool
+	counter   uint32
+}
+
+// Card is a software emulation of the YKOATH applet of a YubiKey.
+// It implements the iso.PCSCCard interface and can hence be passed directly to ykoath.NewCard().
+type Card struct {
+	// Version is the firmware version reported by the applet.
+	// It also determines the features supported by the emulated applet.
+	Version ykoath.Version
+
+	// Serial is the serial number reported by the OTP applet.
+	// The OTP applet is only emulated if it is not zero.
+	Serial uint32
+
+	// MaxCredentials limits the number of credentials which can be stored.
+	MaxCredentials int
+
+	// Touch is invoked whenever a credential requires the user to touch the token.
+	// Returning false emulates a timeout while waiting for the touch.
+	// A nil function emulates an immediate touch.
+	Touch func(name string) bool
+
+	// Rand is used for generating the salt and challenges.
+	Rand io.Reader
+
+	mu sync.Mutex
+
+	credentials []*credential
+
+	salt          []byte
+	challenge     []byte
+	accessKey     []byte
+	algorithm     ykoath.Algorithm
+	authenticated bool
+
+	selected    bool
+	selectedOTP bool
+	remaining   []byte
+	transaction bool
+	removed     bool
+}
+
+// NewCard creates a new emulated OATH applet in its factory state.
+func NewCard() *Card {
+	c := &Card{
+		Version:        ykoath.Version{Major: 5, Minor: 4, Patch: 3},
+		MaxCredentials: DefaultMaxCredentials,
+		Rand:           rand.Reader,
+	}
+
+	c.reset()
+
+	return c
+}
+
+// Transmit implements iso.PCSCCard
+func (c *Card) Transmit(cmd []byte) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.removed {
+		return nil, ErrRemoved
+	}
+
+	capdu, err := parseCAPDU(cmd)
+	if err != nil {
+		return code(iso.Code(errWrongLength)), nil //nolint:nilerr
+	}
+
+	if capdu.Ins != insSendRemaining {
+		c.remaining = nil
+	}
+
+	data, err := c.handle(capdu)
+	if err != nil {
+		var sw ykoath.Error
+		if !errors.As(err, &sw) {
+			return nil, err
+		}
+
+		return code(iso.Code(sw)), nil
+	}
+
+	return c.respond(data), nil
+}
+
+// BeginTransaction implements iso.PCSCCard
+func (c *Card) BeginTransaction() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.removed {
+		return ErrRemoved
+	} else if c.transaction {
+		return ErrTransactionActive
+	}
+
+	c.transaction = true
+
+	return nil
+}
+
+// EndTransaction implements iso.PCSCCard
+func (c *Card) EndTransaction() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.transaction {
+		return ErrNoTransaction
+	}
+
+	c.transaction = false
+
+	return nil
+}
+
+// Close implements iso.PCSCCard
+func (c *Card) Close() error {
+	return nil
+}
+
+// Base implements iso.PCSCCard
+func (c *Card) Base() iso.PCSCCard {
+	return c
+}
+
+// Reconnect implements iso.ReconnectableCard.
+// Reconnecting to the card clears the selected applet and its authentication state.
+func (c *Card) Reconnect(bool) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.removed {
+		return ErrRemoved
+	}
+
+	c.powerCycle()
+
+	return nil
+}
+
+// Remove emulates the removal of the token from the reader.
+// All further operations will fail until Insert() is called.
+func (c *Card) Remove() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.removed = true
+	c.transaction = false
+	c.powerCycle()
+}
+
+// Insert emulates the re-insertion of a previously removed token.
+func (c *Card) Insert() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.removed = false
+}
+
+// Credentials returns the names of all credentials currently stored on the emulated applet.
+func (c *Card) Credentials() (names []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, cred := range c.credentials {
+		names = append(names, string(cred.name))
+	}
+
+	return names
+}
+
+// Counter returns the current counter value of a HOTP credential.
+func (c *Card) Counter(name string) (uint32, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if cred := c.find([]byte(name)); cred != nil {
+		return cred.counter, true
+	}
+
+	return 0, false
+}
+
+func (c *Card) powerCycle() {
+	c.selected = false
+	c.selectedOTP = false
+	c.authenticated = false
+	c.challenge = nil
+	c.remaining = nil
+}
+
+func (c *Card) reset() {
+	c.credentials = nil
+	c.accessKey = nil
+	c.algorithm = 0
+	c.salt = c.random(8)
+	c.powerCycle()
+}
+
+func (c *Card) random(n int) []byte {
+	b := make([]byte, n)
+	if _, err := io.ReadFull(c.Rand, b); err != nil {
+		panic("failed to read random bytes: " + err.Error())
+	}
+
+	return b
+}
+
+func (c *Card) respond(data []byte) []byte {
+	if len(data) > maxResponseLength {
+		c.remaining = data[maxResponseLength:]
+		data = slices.Clone(data[:maxResponseLength])
+
+		sw2 := byte(0)
+		if l := len(c.remaining); l < 0x100 {
+			sw2 = byte(l)
+		}
+
+		return append(data, 0x61, sw2)
+	}
+
+	return append(data, 0x90, 0x00)
+}
+
+func code(sw iso.Code) []byte {
+	return []byte{sw[0], sw[1]}
+}
@@ -0,0 +1,254 @@
+// SPDX-FileCopyrightText: 2023 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package emulator_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	iso "cunicu.li/go-iso7816"
+	"github.com/stretchr/testify/require"
+
+	ykoath "cunicu.li/go-ykoath/v2"
+	"cunicu.li/go-ykoath/v2/emulator"
+)
+
+var testSecret = []byte("12345678901234567890")
+
+func newCard(t *testing.T, emu *emulator.Card) *ykoath.Card {
+	require := require.New(t)
+
+	card, err := ykoath.NewCard(emu)
+	require.NoError(err)
+
+	card.Clock = func() time.Time {
+		return time.Unix(59, 0)
+	}
+
+	_, err = card.Select()
+	require.NoError(err)
+
+	t.Cleanup(func() {
+		require.NoError(card.Close())
+	})
+
+	return card
+}
+
+func TestCalculate(t *testing.T) {
+	require := require.New(t)
+
+	emu := emulator.NewCard()
+	card := newCard(t, emu)
+
+	err := card.Put(ykoath.CredentialID{Account: "totp"}, ykoath.HmacSha1, ykoath.Totp, 8, testSecret, false, 0)
+	require.NoError(err)
+
+	err = card.Put(ykoath.CredentialID{Account: "hotp"}, ykoath.HmacSha1, ykoath.Hotp, 6, testSecret, false, 0)
+	require.NoError(err)
+
+	code, err := card.Calculate("totp")
+	require.NoError(err)
+	require.Equal("94287082", code)
+
+	// See: RFC 4226 Appendix D - HOTP Algorithm: Test Values
+	for _, expected := range []string{"755224", "287082", "359152"} {
+		code, err := card.Calculate("hotp")
+		require.NoError(err)
+		require.Equal(expected, code)
+	}
+
+	counter, ok := emu.Counter("hotp")
+	require.True(ok)
+	require.EqualValues(3, counter)
+
+	err = card.Delete("totp")
+	require.NoError(err)
+	require.Equal([]string{"hotp"}, emu.Credentials())
+
+	_, err = card.Calculate("totp")
+	require.ErrorIs(err, ykoath.ErrNoSuchObject)
+}
+
+func TestTouch(t *testing.T) {
+	require := require.New(t)
+
+	emu := emulator.NewCard()
+	card := newCard(t, emu)
+
+	err := card.Put(ykoath.CredentialID{Account: "touch"}, ykoath.HmacSha1, ykoath.Totp, 8, testSecret, true, 0)
+	require.NoError(err)
+
+	var touched []string
+	emu.Touch = func(name string) bool {
+		touched = append(touched, name)
+		return len(touched) > 1
+	}
+
+	calcs, err := card.CalculateAll()
+	require.NoError(err)
+	require.Len(calcs, 1)
+	require.True(calcs[0].TouchRequired)
+	require.Empty(touched)
+
+	// The first touch times out
+	_, err = card.Calculate("touch")
+	require.ErrorIs(err, ykoath.Error{0x69, 0x85})
+
+	code, err := card.Calculate("touch")
+	require.NoError(err)
+	require.Equal("94287082", code)
+	require.Equal([]string{"touch", "touch"}, touched)
+}
+
+func TestAuthentication(t *testing.T) {
+	require := require.New(t)
+
+	emu := emulator.NewCard()
+	card := newCard(t, emu)
+
+	err := card.SetCode([]byte("1338"), ykoath.HmacSha256)
+	require.NoError(err)
+
+	_, err = card.List()
+	require.NoError(err)
+
+	// Reconnecting drops the authentication
+	err = emu.Reconnect(false)
+	require.NoError(err)
+
+	sel, err := card.Select()
+	require.NoError(err)
+	require.NotNil(sel.Challenge)
+
+	_, err = card.List()
+	require.ErrorIs(err, ykoath.ErrAuthRequired)
+
+	err = card.Validate([]byte("1337"))
+	require.ErrorIs(err, ykoath.ErrWrongSyntax)
+
+	err = card.Validate([]byte("1338"))
+	require.NoError(err)
+
+	_, err = card.List()
+	require.NoError(err)
+
+	err = card.RemoveCode()
+	require.NoError(err)
+
+	sel, err = card.Select()
+	require.NoError(err)
+	require.Nil(sel.Challenge)
+
+	_, err = card.List()
+	require.NoError(err)
+}
+
+func TestChaining(t *testing.T) {
+	require := require.New(t)
+
+	emu := emulator.NewCard()
+	card := newCard(t, emu)
+
+	// Responses exceeding 255 bytes require SEND REMAINING
+	for i := range 20 {
+		err := card.Put(ykoath.CredentialID{Account: fmt.Sprintf("%s-%02d", strings.Repeat("x", 40), i)}, ykoath.HmacSha1, ykoath.Totp, 8, testSecret, false, 0)
+		require.NoError(err)
+	}
+
+	names, err := card.List()
+	require.NoError(err)
+	require.Len(names, 20)
+
+	calcs, err := card.CalculateAll()
+	require.NoError(err)
+	require.Len(calcs, 20)
+
+	for _, calc := range calcs {
+		require.Equal("94287082", calc.OTP())
+	}
+}
+
+func TestStorageLimit(t *testing.T) {
+	require := require.New(t)
+
+	emu := emulator.NewCard()
+	emu.MaxCredentials = 2
+	card := newCard(t, emu)
+
+	for _, name := range []string{"a", "b", "a"} {
+		err := card.Put(ykoath.CredentialID{Account: name}, ykoath.HmacSha1, ykoath.Totp, 6, testSecret, false, 0)
+		require.NoError(err)
+	}
+
+	err := card.Put(ykoath.CredentialID{Account: "c"}, ykoath.HmacSha1, ykoath.Totp, 6, testSecret, false, 0)
+	require.ErrorIs(err, ykoath.ErrNoSpace)
+
+	err = card.Reset()
+	require.NoError(err)
+	require.Empty(emu.Credentials())
+}
+
+func TestVersion(t *testing.T) {
+	require := require.New(t)
+
+	emu := emulator.NewCard()
+	emu.Version = ykoath.Version{Major: 4, Minor: 2, Patch: 4}
+
+	card, err := ykoath.NewCard(emu)
+	require.NoError(err)
+
+	t.Cleanup(func() {
+		require.NoError(card.Close())
+	})
+
+	_, err = card.List()
+	require.ErrorIs(err, ykoath.Error{0x6d, 0x00})
+
+	// Select the applet without the client-side feature checks
+	_, err = card.Card.Select(iso.AidYubicoOATH)
+	require.NoError(err)
+
+	err = card.Put(ykoath.CredentialID{Account: "touch"}, ykoath.HmacSha1, ykoath.Totp, 6, testSecret, true, 0)
+	require.ErrorIs(err, ykoath.ErrWrongSyntax)
+
+	err = card.Put(ykoath.CredentialID{Account: "sha512"}, ykoath.HmacSha512, ykoath.Totp, 6, testSecret, false, 0)
+	require.ErrorIs(err, ykoath.ErrWrongSyntax)
+
+	err = card.Rename("a", "b")
+	require.ErrorIs(err, ykoath.Error{0x6d, 0x00})
+
+	sel, err := card.Select()
+	require.NoError(err)
+	require.Equal(emu.Version, sel.Version)
+}
+
+func TestRemove(t *testing.T) {
+	require := require.New(t)
+
+	emu := emulator.NewCard()
+	card := newCard(t, emu)
+
+	emu.Remove()
+
+	_, err := card.List()
+	require.ErrorIs(err, emulator.ErrRemoved)
+
+	emu.Insert()
+
+	// The applet needs to be selected again after re-insertion
+	_, err = card.List()
+	require.ErrorIs(err, ykoath.Error{0x6d, 0x00})
+
+	err = emu.BeginTransaction()
+	require.NoError(err)
+
+	_, err = card.Select()
+	require.NoError(err)
+
+	_, err = card.List()
+	require.NoError(err)
+}
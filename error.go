@@ -4,18 +4,29 @@
 package ykoath
 
 import (
+	"errors"
+	"fmt"
+	"strings"
+
 	iso "cunicu.li/go-iso7816"
+	"cunicu.li/go-iso7816/encoding/tlv"
 )
 
+// Error is a status word returned by the applet
 type Error iso.Code
 
 var (
-	ErrAuthRequired         = Error{0x69, 0x82}
-	ErrGeneric              = Error{0x65, 0x81}
-	ErrNoSpace              = Error{0x6a, 0x84}
-	ErrNoSuchObject         = Error{0x69, 0x84}
-	ErrResponseDoesNotMatch = Error{0x69, 0x84}
-	ErrWrongSyntax          = Error{0x6a, 0x80}
+	ErrAuthRequired = Error{0x69, 0x82}
+	ErrGeneric      = Error{0x65, 0x81}
+	ErrNoSpace      = Error{0x6a, 0x84}
+	ErrNoSuchObject = Error{0x69, 0x84}
+	ErrWrongSyntax  = Error{0x6a, 0x80}
+	ErrTouchTimeout = Error{0x69, 0x85}
+
+	// ErrResponseDoesNotMatch is returned instead of ErrNoSuchObject if the status
+	// word 0x6984 is returned by the "VALIDATE" instruction, i.e. the response to
+	// the challenge of the applet is wrong.
+	ErrResponseDoesNotMatch = errors.New("response does not match")
 )
 
 // Error return the encapsulated error string
@@ -33,12 +44,12 @@ func (e Error) Error() string {
 	case ErrNoSuchObject:
 		return "no such object"
 
-	case ErrResponseDoesNotMatch:
-		return "response does not match"
-
 	case ErrWrongSyntax:
 		return "wrong syntax"
 
+	case ErrTouchTimeout:
+		return "touch timeout"
+
 	default:
 		c := iso.Code(e)
 		return c.Error()
@@ -57,3 +68,126 @@ func wrapError(err error) error {
 
 	return err
 }
+
+// CommandError is returned if the applet rejects a command.
+// It records the instruction, the name of the credential and the status word.
+// The status word is unwrapped according to its meaning for the instruction.
+type CommandError struct {
+	Instruction iso.Instruction
+	Name        string
+	Code        Error
+}
+
+func (e *CommandError) Error() string {
+	cmd := instructionName(e.Instruction)
+	if e.Name != "" {
+		cmd = fmt.Sprintf("%s %q", cmd, e.Name)
+	}
+
+	return fmt.Sprintf("%s failed: %s", cmd, e.Unwrap())
+}
+
+// Unwrap returns the error corresponding to the status word.
+// The status word 0x6984 is ambiguous and either unwraps to ErrNoSuchObject
+// or ErrResponseDoesNotMatch depending on the instruction.
+func (e *CommandError) Unwrap() error {
+	if e.Code == ErrNoSuchObject && e.Instruction == insValidate {
+		return ErrResponseDoesNotMatch
+	}
+
+	return e.Code
+}
+
+// wrapCommandError wraps status words returned for a command in a CommandError
+func wrapCommandError(err error, ins iso.Instruction, tvs []tlv.TagValue) error {
+	var code iso.Code
+	if !errors.As(err, &code) {
+		return err
+	}
+
+	e := &CommandError{
+		Instruction: ins,
+		Code:        Error(code),
+	}
+
+	for _, tv := range tvs {
+		if tv.Tag == tagName {
+			e.Name = string(tv.Value)
+			break
+		}
+	}
+
+	return e
+}
+
+func instructionName(ins iso.Instruction) string {
+	switch ins {
+	case insPut:
+		return "PUT"
+
+	case insDelete:
+		return "DELETE"
+
+	case insSetCode:
+		return "SET CODE"
+
+	case insReset:
+		return "RESET"
+
+	case insRename:
+		return "RENAME"
+
+	case insList:
+		return "LIST"
+
+	case insCalculate:
+		return "CALCULATE"
+
+	case insValidate:
+		return "VALIDATE"
+
+	case insCalculateAll:
+		return "CALCULATE ALL"
+
+	case insSendRemaining:
+		return "SEND REMAINING"
+
+	default:
+		return fmt.Sprintf("instruction %#02x", byte(ins))
+	}
+}
+
+// ValidationError describes a single invalid parameter of a credential
+type ValidationError struct {
+	Field string
+	Err   error
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid " + e.Field + ": " + e.Err.Error()
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+// ValidationErrors collects all problems found while validating a credential
+type ValidationErrors []*ValidationError
+
+func (e ValidationErrors) Error() string {
+	msgs := make([]string, 0, len(e))
+	for _, err := range e {
+		msgs = append(msgs, err.Error())
+	}
+
+	return strings.Join(msgs, "; ")
+}
+
+func (e ValidationErrors) Unwrap() []error {
+	errs := make([]error, 0, len(e))
+	for _, err := range e {
+		errs = append(errs, err)
+	}
+
+	return errs
+}
@@ -0,0 +1,55 @@
+// SPDX-FileCopyrightText: 2023 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package ykoath_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	ykoath "cunicu.li/go-ykoath/v2"
+	"cunicu.li/go-ykoath/v2/emulator"
+)
+
+func TestCommandError(t *testing.T) {
+	require := require.New(t)
+
+	// Instruction bytes of VALIDATE and DELETE
+	validate := &ykoath.CommandError{Instruction: 0xa3, Code: ykoath.Error{0x69, 0x84}}
+	require.ErrorIs(validate, ykoath.ErrResponseDoesNotMatch)
+	require.NotErrorIs(validate, ykoath.ErrNoSuchObject)
+	require.True(ykoath.IsWrongKey(validate))
+	require.EqualError(validate, "VALIDATE failed: response does not match")
+
+	del := &ykoath.CommandError{Instruction: 0x02, Name: "test", Code: ykoath.Error{0x69, 0x84}}
+	require.ErrorIs(del, ykoath.ErrNoSuchObject)
+	require.NotErrorIs(del, ykoath.ErrResponseDoesNotMatch)
+	require.False(ykoath.IsWrongKey(del))
+	require.EqualError(del, `DELETE "test" failed: no such object`)
+}
+
+func TestCommandErrorContext(t *testing.T) {
+	require := require.New(t)
+
+	card, err := ykoath.NewCard(emulator.NewCard())
+	require.NoError(err)
+
+	defer card.Close()
+
+	_, err = card.Select()
+	require.NoError(err)
+
+	err = card.Delete("unknown")
+	require.ErrorIs(err, ykoath.ErrNoSuchObject)
+
+	var cmdErr *ykoath.CommandError
+	require.ErrorAs(err, &cmdErr)
+	require.Equal("unknown", cmdErr.Name)
+	require.EqualError(cmdErr, `DELETE "unknown" failed: no such object`)
+	require.Equal(ykoath.ErrNoSuchObject, cmdErr.Code)
+
+	var sw ykoath.Error
+	require.ErrorAs(err, &sw)
+	require.Equal(ykoath.ErrNoSuchObject, sw)
+}
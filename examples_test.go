@@ -56,7 +56,7 @@ func Example() {
 	// }
 
 	// Add the testvector
-	if err = c.Put("testvector", ykoath.HmacSha1, ykoath.Totp, 8, []byte("12345678901234567890"), false, 0); err != nil {
+	if err = c.Put(ykoath.CredentialID{Account: "testvector"}, ykoath.HmacSha1, ykoath.Totp, 8, []byte("12345678901234567890"), false, 0); err != nil {
 		log.Printf("Failed to put: %v", err)
 		return
 	}
@@ -71,7 +71,7 @@ func Example() {
 		fmt.Printf("Name: %s\n", name)
 	}
 
-	otp, _ := c.CalculateMatch("testvector", nil)
+	otp, _ := c.CalculateMatch("testvector")
 	fmt.Printf("OTP: %s\n", otp)
 
 	// Output:
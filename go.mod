@@ -9,13 +9,13 @@ toolchain go1.24.5
 
 require (
 	cunicu.li/go-iso7816 v0.8.8
+	github.com/ebfe/scard v0.0.0-20241214075232-7af069cabc25
 	golang.org/x/crypto v0.42.0
+	golang.org/x/sys v0.36.0
+	golang.org/x/term v0.35.0
 )
 
-require (
-	github.com/ebfe/scard v0.0.0-20241214075232-7af069cabc25 // test-only
-	github.com/stretchr/testify v1.11.1 // test-only
-)
+require github.com/stretchr/testify v1.11.1 // test-only
 
 require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
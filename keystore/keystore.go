@@ -0,0 +1,373 @@
+// SPDX-FileCopyrightText: 2023 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+// Package keystore persists derived OATH access keys per device
+// in a file which is encrypted at rest using AES-GCM.
+package keystore
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"golang.org/x/crypto/scrypt"
+
+	ykoath "cunicu.li/go-ykoath/v2"
+)
+
+const (
+	// KeySize is the size of the key used to encrypt the store
+	KeySize = 32
+
+	fileVersion = 1
+	saltSize    = 16
+)
+
+var (
+	ErrNotFound        = errors.New("no access key stored for device")
+	ErrInvalidKeySize  = errors.New("invalid key size")
+	ErrDecrypt         = errors.New("failed to decrypt store: wrong key or passphrase")
+	ErrInvalidVersion  = errors.New("unsupported store version")
+	ErrInvalidDeviceID = errors.New("invalid device ID")
+)
+
+// DefaultScryptParams are the scrypt parameters used for new stores
+// which are protected by a passphrase.
+var DefaultScryptParams = ScryptParams{ //nolint:gochecknoglobals
+	N: 1 << 15,
+	R: 8,
+	P: 1,
+}
+
+// ScryptParams are the parameters for deriving the encryption key from a passphrase.
+type ScryptParams struct {
+	Salt []byte `json:"salt"`
+	N    int    `json:"n"`
+	R    int    `json:"r"`
+	P    int    `json:"p"`
+}
+
+func (p *ScryptParams) key(passphrase []byte) ([]byte, error) {
+	return scrypt.Key(passphrase, p.Salt, p.N, p.R, p.P, KeySize)
+}
+
+// file is the on-disk representation of the store
+type file struct {
+	Version int           `json:"version"`
+	Scrypt  *ScryptParams `json:"scrypt,omitempty"`
+	Nonce   []byte        `json:"nonce"`
+	Data    []byte        `json:"data"`
+}
+
+// Store is a set of access keys indexed by the device ID of the applet.
+// All changes are immediately written to disk.
+//
+// Several processes may share a store. Changes are made while holding an
+// advisory lock on the file "<path>.lock" and are merged with the changes
+// which other processes have written since the store has been opened.
+// Lookups are served from the state read by Open or the last change.
+type Store struct {
+	path   string
+	aead   cipher.AEAD
+	scrypt *ScryptParams
+
+	mu   sync.Mutex
+	keys map[string][]byte
+}
+
+// Open opens the store at path using a key of KeySize bytes.
+// A new store is created on the first change if the file does not exist yet.
+func Open(path string, key []byte) (*Store, error) {
+	f, err := readFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return open(path, f, key, nil)
+}
+
+// OpenWithPassphrase opens the store at path using a key derived from a passphrase.
+// A new store is created on the first change if the file does not exist yet.
+func OpenWithPassphrase(path string, passphrase []byte) (*Store, error) {
+	f, err := readFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	params := f.Scrypt
+	if params == nil {
+		p := DefaultScryptParams
+		p.Salt = make([]byte, saltSize)
+		if _, err := rand.Read(p.Salt); err != nil {
+			return nil, fmt.Errorf("failed to generate salt: %w", err)
+		}
+
+		params = &p
+	}
+
+	key, err := params.key(passphrase)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive key: %w", err)
+	}
+
+	return open(path, f, key, params)
+}
+
+func open(path string, f *file, key []byte, params *ScryptParams) (*Store, error) {
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("%w: expected %d bytes, got %d", ErrInvalidKeySize, KeySize, len(key))
+	}
+
+	blk, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+
+	aead, err := cipher.NewGCM(blk)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+
+	s := &Store{
+		path:   path,
+		aead:   aead,
+		scrypt: params,
+	}
+
+	keys, err := s.decode(f)
+	if err != nil {
+		return nil, err
+	}
+
+	s.keys = keys
+
+	return s, nil
+}
+
+// decode decrypts the keys of a file
+func (s *Store) decode(f *file) (map[string][]byte, error) {
+	keys := map[string][]byte{}
+
+	if f.Data == nil {
+		return keys, nil
+	}
+
+	if len(f.Nonce) != s.aead.NonceSize() {
+		return nil, ErrDecrypt
+	}
+
+	data, err := s.aead.Open(nil, f.Nonce, f.Data, nil)
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return nil, fmt.Errorf("failed to decode store: %w", err)
+	}
+
+	return keys, nil
+}
+
+// Add stores the access key of a device.
+// An existing key of the same device is replaced.
+func (s *Store) Add(deviceID string, key []byte) error {
+	if deviceID == "" {
+		return ErrInvalidDeviceID
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.update(func(keys map[string][]byte) bool {
+		keys[deviceID] = key
+		return true
+	})
+}
+
+// Lookup returns the access key of a device.
+func (s *Store) Lookup(deviceID string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key, ok := s.keys[deviceID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, deviceID)
+	}
+
+	return key, nil
+}
+
+// Forget removes the access key of a device.
+// It is not an error if no key is stored for the device.
+func (s *Store) Forget(deviceID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.update(func(keys map[string][]byte) bool {
+		if _, ok := keys[deviceID]; !ok {
+			return false
+		}
+
+		delete(keys, deviceID)
+
+		return true
+	})
+}
+
+// Devices returns the IDs of all devices with a stored access key.
+func (s *Store) Devices() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ids := make([]string, 0, len(s.keys))
+	for id := range s.keys {
+		ids = append(ids, id)
+	}
+
+	return ids
+}
+
+// KeyProvider can be used as ykoath.Card.KeyProvider
+// in order to authenticate with a stored access key.
+func (s *Store) KeyProvider(sel *ykoath.Select) ([]byte, error) {
+	key, err := s.Lookup(sel.DeviceID())
+	if errors.Is(err, ErrNotFound) {
+		return nil, nil
+	}
+
+	return key, err
+}
+
+// update applies a change to the keys while holding the lock of the store.
+// The file is read again before so that changes of other processes are retained.
+// fn returns false if the keys have not been changed.
+func (s *Store) update(fn func(keys map[string][]byte) bool) error {
+	unlock, err := s.lock()
+	if err != nil {
+		return err
+	}
+
+	defer unlock()
+
+	f, err := readFile(s.path)
+	if err != nil {
+		return err
+	}
+
+	keys, err := s.decode(f)
+	if err != nil {
+		return err
+	}
+
+	s.keys = keys
+
+	if !fn(keys) {
+		return nil
+	}
+
+	return s.save()
+}
+
+// lock acquires an advisory lock on the lock file of the store.
+// A separate file is used as the store itself is replaced on every change.
+func (s *Store) lock() (unlock func(), err error) {
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	f, err := os.OpenFile(s.path+".lock", os.O_CREATE|os.O_RDWR, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock file: %w", err)
+	}
+
+	if err := lockFile(f); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to lock store: %w", err)
+	}
+
+	return func() {
+		unlockFile(f) //nolint:errcheck
+		f.Close()
+	}, nil
+}
+
+// save atomically replaces the file by writing to a temporary file first
+func (s *Store) save() error {
+	data, err := json.Marshal(s.keys)
+	if err != nil {
+		return fmt.Errorf("failed to encode store: %w", err)
+	}
+
+	f := &file{
+		Version: fileVersion,
+		Scrypt:  s.scrypt,
+		Nonce:   make([]byte, s.aead.NonceSize()),
+	}
+
+	if _, err := rand.Read(f.Nonce); err != nil {
+		return fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	f.Data = s.aead.Seal(nil, f.Nonce, data, nil)
+
+	buf, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode store: %w", err)
+	}
+
+	dir := filepath.Dir(s.path)
+
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(s.path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+
+	defer os.Remove(tmp.Name()) //nolint:errcheck
+
+	if _, err := tmp.Write(buf); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write store: %w", err)
+	}
+
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write store: %w", err)
+	}
+
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write store: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("failed to replace store: %w", err)
+	}
+
+	return nil
+}
+
+func readFile(path string) (*file, error) {
+	buf, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return &file{}, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read store: %w", err)
+	}
+
+	f := &file{}
+	if err := json.Unmarshal(buf, f); err != nil {
+		return nil, fmt.Errorf("failed to decode store: %w", err)
+	}
+
+	if f.Version != fileVersion {
+		return nil, fmt.Errorf("%w: %d", ErrInvalidVersion, f.Version)
+	}
+
+	return f, nil
+}
@@ -0,0 +1,141 @@
+// SPDX-FileCopyrightText: 2023 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package keystore_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	ykoath "cunicu.li/go-ykoath/v2"
+	"cunicu.li/go-ykoath/v2/keystore"
+)
+
+func TestStore(t *testing.T) {
+	require := require.New(t)
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "keys.json")
+	key := bytes.Repeat([]byte{0x42}, keystore.KeySize)
+	accessKey := []byte("0123456789abcdef")
+
+	s, err := keystore.Open(path, key)
+	require.NoError(err)
+
+	_, err = s.Lookup("device")
+	require.ErrorIs(err, keystore.ErrNotFound)
+
+	err = s.Add("device", accessKey)
+	require.NoError(err)
+
+	err = s.Add("", accessKey)
+	require.ErrorIs(err, keystore.ErrInvalidDeviceID)
+
+	// Keys are not stored in plain text
+	buf, err := os.ReadFile(path)
+	require.NoError(err)
+	require.NotContains(string(buf), string(accessKey))
+
+	fi, err := os.Stat(path)
+	require.NoError(err)
+	require.Equal(os.FileMode(0o600), fi.Mode().Perm())
+
+	// No temporary files are left behind
+	entries, err := os.ReadDir(dir)
+	require.NoError(err)
+	require.Len(entries, 2)
+	require.Equal("keys.json", entries[0].Name())
+	require.Equal("keys.json.lock", entries[1].Name())
+
+	s, err = keystore.Open(path, key)
+	require.NoError(err)
+	require.Equal([]string{"device"}, s.Devices())
+
+	k, err := s.Lookup("device")
+	require.NoError(err)
+	require.Equal(accessKey, k)
+
+	_, err = keystore.Open(path, bytes.Repeat([]byte{0x43}, keystore.KeySize))
+	require.ErrorIs(err, keystore.ErrDecrypt)
+
+	_, err = keystore.Open(path, key[:16])
+	require.ErrorIs(err, keystore.ErrInvalidKeySize)
+
+	err = s.Forget("device")
+	require.NoError(err)
+
+	s, err = keystore.Open(path, key)
+	require.NoError(err)
+	require.Empty(s.Devices())
+}
+
+func TestStorePassphrase(t *testing.T) {
+	require := require.New(t)
+
+	// Speed up tests
+	params := keystore.DefaultScryptParams
+	keystore.DefaultScryptParams.N = 1 << 10
+
+	t.Cleanup(func() {
+		keystore.DefaultScryptParams = params
+	})
+
+	path := filepath.Join(t.TempDir(), "keys.json")
+	accessKey := []byte("0123456789abcdef")
+	sel := &ykoath.Select{
+		Name: []byte{0xf8, 0xfa, 0xaa, 0x1d, 0x91, 0x91, 0x8e, 0x28},
+	}
+
+	s, err := keystore.OpenWithPassphrase(path, []byte("secret"))
+	require.NoError(err)
+
+	k, err := s.KeyProvider(sel)
+	require.NoError(err)
+	require.Nil(k)
+
+	err = s.Add(sel.DeviceID(), accessKey)
+	require.NoError(err)
+
+	_, err = keystore.OpenWithPassphrase(path, []byte("wrong"))
+	require.ErrorIs(err, keystore.ErrDecrypt)
+
+	s, err = keystore.OpenWithPassphrase(path, []byte("secret"))
+	require.NoError(err)
+
+	k, err = s.KeyProvider(sel)
+	require.NoError(err)
+	require.Equal(accessKey, k)
+}
+
+func TestStoreConcurrent(t *testing.T) {
+	require := require.New(t)
+
+	path := filepath.Join(t.TempDir(), "keys.json")
+	key := bytes.Repeat([]byte{0x42}, keystore.KeySize)
+
+	s1, err := keystore.Open(path, key)
+	require.NoError(err)
+
+	s2, err := keystore.Open(path, key)
+	require.NoError(err)
+
+	// Changes of other stores are merged rather than overwritten
+	err = s1.Add("device1", []byte("key1"))
+	require.NoError(err)
+
+	err = s2.Add("device2", []byte("key2"))
+	require.NoError(err)
+
+	require.ElementsMatch([]string{"device1", "device2"}, s2.Devices())
+
+	err = s1.Forget("device2")
+	require.NoError(err)
+
+	s, err := keystore.Open(path, key)
+	require.NoError(err)
+	require.Equal([]string{"device1"}, s.Devices())
+}
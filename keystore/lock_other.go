@@ -0,0 +1,19 @@
+// SPDX-FileCopyrightText: 2023 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build !unix && !windows
+
+package keystore
+
+import "os"
+
+// Advisory file locks are not available on this platform.
+// Hence, only a single process may modify the store at a time.
+
+func lockFile(*os.File) error {
+	return nil
+}
+
+func unlockFile(*os.File) error {
+	return nil
+}
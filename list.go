@@ -4,6 +4,7 @@
 package ykoath
 
 import (
+	"context"
 	"fmt"
 )
 
@@ -12,6 +13,7 @@ type Name struct {
 	Algorithm Algorithm
 	Type      Type
 	Name      string
+	ID        CredentialID
 }
 
 // String returns a string representation of the algorithm
@@ -21,9 +23,20 @@ func (n *Name) String() string {
 
 // List sends a "LIST" instruction, return a list of OATH credentials
 func (c *Card) List() ([]*Name, error) {
+	return c.ListContext(context.Background())
+}
+
+// ListContext is like List but aborts when the context is done
+func (c *Card) ListContext(ctx context.Context) ([]*Name, error) {
+	if err := c.acquire(ctx); err != nil {
+		return nil, err
+	}
+
+	defer c.release()
+
 	var names []*Name
 
-	tvs, err := c.send(insList, 0x00, 0x00)
+	tvs, err := c.send(ctx, insList, 0x00, 0x00)
 	if err != nil {
 		return nil, err
 	}
@@ -35,6 +48,7 @@ func (c *Card) List() ([]*Name, error) {
 				Algorithm: Algorithm(tv.Value[0] & 0x0f),
 				Name:      string(tv.Value[1:]),
 				Type:      Type(tv.Value[0] & 0xf0),
+				ID:        parseName(tv.Value[1:], Type(tv.Value[0]&0xf0)),
 			}
 
 			names = append(names, name)
@@ -9,6 +9,7 @@ import (
 	"github.com/stretchr/testify/require"
 
 	ykoath "cunicu.li/go-ykoath/v2"
+	"cunicu.li/go-ykoath/v2/emulator"
 )
 
 func TestList(t *testing.T) {
@@ -33,6 +34,42 @@ func TestList(t *testing.T) {
 			require.Equal(vector.Alg, r.Algorithm)
 			require.Equal(vector.Name, r.Name)
 			require.Equal(vector.Typ, r.Type)
+			require.Equal(ykoath.CredentialID{
+				Period:  ykoath.DefaultTimeStep,
+				Account: vector.Name,
+			}, r.ID)
+		}
+	})
+}
+
+func TestListUndecodableName(t *testing.T) {
+	withEmulator(t, emulator.NewCard(), vectorsTOTP[:1], func(t *testing.T, card *ykoath.Card) {
+		require := require.New(t)
+
+		// Other applications might store names which can not be decoded
+		oldName := vectorsTOTP[0].Name
+
+		for _, name := range []string{"0/foo", "18446744073/foo"} {
+			err := card.Rename(oldName, name)
+			require.NoError(err)
+
+			oldName = name
+
+			names, err := card.List()
+			require.NoError(err)
+			require.Len(names, 1)
+			require.Equal(ykoath.CredentialID{
+				Period:  ykoath.DefaultTimeStep,
+				Account: name,
+			}, names[0].ID)
+
+			calcs, err := card.CalculateAll()
+			require.NoError(err)
+			require.Len(calcs, 1)
+			require.Equal(names[0].ID, calcs[0].ID)
+
+			_, err = card.Calculate(name)
+			require.NoError(err)
 		}
 	})
 }
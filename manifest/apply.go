@@ -0,0 +1,210 @@
+// SPDX-FileCopyrightText: 2023 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package manifest
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	ykoath "cunicu.li/go-ykoath/v2"
+)
+
+const (
+	// Keep denotes a credential of the manifest which is already present on the card.
+	Keep Action = iota
+
+	// Add denotes a credential of the manifest which is missing on the card.
+	Add
+
+	// Delete denotes a credential on the card which is not listed in the manifest.
+	Delete
+)
+
+// Action is the change which is applied to a single credential
+type Action int
+
+// String returns a string representation of the action
+func (a Action) String() string {
+	switch a {
+	case Keep:
+		return "keep"
+
+	case Add:
+		return "add"
+
+	case Delete:
+		return "delete"
+
+	default:
+		return fmt.Sprintf("unknown %d", int(a))
+	}
+}
+
+// Change is a single step of a plan.
+type Change struct {
+	Action Action
+	Name   string
+
+	// Credential is the credential which is added to the card.
+	// It is only set for the Add action.
+	Credential *ykoath.CredentialData
+}
+
+// Plan lists the changes which are required to bring a card in line with a manifest.
+// Credentials are deleted before new ones are added.
+type Plan struct {
+	Changes []Change
+}
+
+// Count returns the number of changes with the given action.
+func (p *Plan) Count(a Action) int {
+	n := 0
+	for _, c := range p.Changes {
+		if c.Action == a {
+			n++
+		}
+	}
+
+	return n
+}
+
+// Empty returns true if the card already matches the manifest.
+func (p *Plan) Empty() bool {
+	return p.Count(Add) == 0 && p.Count(Delete) == 0
+}
+
+// String returns a human readable representation of the plan
+func (p *Plan) String() string {
+	var sb strings.Builder
+
+	for _, c := range p.Changes {
+		switch c.Action {
+		case Add:
+			sb.WriteString("+ ")
+
+		case Delete:
+			sb.WriteString("- ")
+
+		default:
+			sb.WriteString("  ")
+		}
+
+		sb.WriteString(c.Name)
+		sb.WriteByte('\n')
+	}
+
+	fmt.Fprintf(&sb, "%d to add, %d to delete, %d unchanged\n", p.Count(Add), p.Count(Delete), p.Count(Keep))
+
+	return sb.String()
+}
+
+// Options control how a manifest is applied.
+type Options struct {
+	// Prune deletes credentials from the card which are not listed in the manifest.
+	Prune bool
+
+	// DryRun only computes the plan without modifying the card.
+	DryRun bool
+
+	// Confirm is called with the plan before the card is modified.
+	// The card is left untouched if it returns an error.
+	// It is not called if the plan is empty.
+	Confirm func(p *Plan) error
+}
+
+// NewPlan compares the credentials on the card with the manifest.
+// Existing credentials are identified by their name only and are
+// never overwritten as their secrets can not be read back.
+func NewPlan(ctx context.Context, card *ykoath.Card, m *Manifest, prune bool) (*Plan, error) {
+	ds, err := m.Resolve()
+	if err != nil {
+		return nil, err
+	}
+
+	names, err := card.ListContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list credentials: %w", err)
+	}
+
+	listed := map[string]bool{}
+	for _, d := range ds {
+		listed[d.ID.String()] = true
+	}
+
+	existing := map[string]bool{}
+	p := &Plan{}
+
+	for _, n := range names {
+		existing[n.Name] = true
+
+		if !listed[n.Name] && prune {
+			p.Changes = append(p.Changes, Change{
+				Action: Delete,
+				Name:   n.Name,
+			})
+		}
+	}
+
+	for _, d := range ds {
+		name := d.ID.String()
+
+		c := Change{
+			Action: Keep,
+			Name:   name,
+		}
+
+		if !existing[name] {
+			c.Action = Add
+			c.Credential = d
+		}
+
+		p.Changes = append(p.Changes, c)
+	}
+
+	return p, nil
+}
+
+// Apply brings the card in line with the manifest by adding missing
+// credentials and, if requested, deleting the ones which are not listed.
+// The manifest is resolved and validated completely before the card is modified.
+// It returns the plan which has been applied.
+func Apply(ctx context.Context, card *ykoath.Card, m *Manifest, opts *Options) (*Plan, error) {
+	if opts == nil {
+		opts = &Options{}
+	}
+
+	p, err := NewPlan(ctx, card, m, opts.Prune)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.DryRun || p.Empty() {
+		return p, nil
+	}
+
+	if opts.Confirm != nil {
+		if err := opts.Confirm(p); err != nil {
+			return p, err
+		}
+	}
+
+	for _, c := range p.Changes {
+		switch c.Action {
+		case Add:
+			if err := card.PutCredentialContext(ctx, c.Credential); err != nil {
+				return p, fmt.Errorf("failed to add %s: %w", c.Name, err)
+			}
+
+		case Delete:
+			if err := card.DeleteContext(ctx, c.Name); err != nil {
+				return p, fmt.Errorf("failed to delete %s: %w", c.Name, err)
+			}
+
+		case Keep:
+		}
+	}
+
+	return p, nil
+}
@@ -0,0 +1,99 @@
+// SPDX-FileCopyrightText: 2023 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package manifest_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	ykoath "cunicu.li/go-ykoath/v2"
+	"cunicu.li/go-ykoath/v2/emulator"
+	"cunicu.li/go-ykoath/v2/manifest"
+)
+
+var errDeclined = errors.New("declined")
+
+func TestApply(t *testing.T) {
+	require := require.New(t)
+
+	t.Setenv("YKOATH_TEST_SECRET", "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ")
+
+	m, err := manifest.Load("testdata/manifest.json")
+	require.NoError(err)
+
+	card, err := ykoath.NewCard(emulator.NewCard())
+	require.NoError(err)
+
+	defer card.Close()
+
+	_, err = card.Select()
+	require.NoError(err)
+
+	err = card.Put(ykoath.CredentialID{Issuer: "Example", Account: "ci@example.com"}, ykoath.HmacSha1, ykoath.Totp, 6, []byte("secret"), false, 0)
+	require.NoError(err)
+
+	err = card.Put(ykoath.CredentialID{Account: "stale"}, ykoath.HmacSha1, ykoath.Totp, 6, []byte("secret"), false, 0)
+	require.NoError(err)
+
+	ctx := context.Background()
+
+	// A dry-run only reports the plan
+	p, err := manifest.Apply(ctx, card, m, &manifest.Options{
+		Prune:  true,
+		DryRun: true,
+	})
+	require.NoError(err)
+	require.Equal(`- stale
+  Example:ci@example.com
++ 60/SPDX:deploy
++ backup
+2 to add, 1 to delete, 1 unchanged
+`, p.String())
+
+	// The card is left untouched if the plan is declined
+	_, err = manifest.Apply(ctx, card, m, &manifest.Options{
+		Confirm: func(p *manifest.Plan) error {
+			require.Equal(2, p.Count(manifest.Add))
+			require.Zero(p.Count(manifest.Delete))
+
+			return errDeclined
+		},
+	})
+	require.ErrorIs(err, errDeclined)
+
+	names, err := card.List()
+	require.NoError(err)
+	require.Len(names, 2)
+
+	// Without pruning, unlisted credentials are kept
+	_, err = manifest.Apply(ctx, card, m, nil)
+	require.NoError(err)
+
+	names, err = card.List()
+	require.NoError(err)
+	require.Len(names, 4)
+
+	p, err = manifest.Apply(ctx, card, m, &manifest.Options{
+		Prune: true,
+	})
+	require.NoError(err)
+	require.Equal(1, p.Count(manifest.Delete))
+
+	names, err = card.List()
+	require.NoError(err)
+	require.Len(names, 3)
+
+	// Applying the manifest again does not change anything
+	p, err = manifest.Apply(ctx, card, m, &manifest.Options{
+		Prune: true,
+		Confirm: func(*manifest.Plan) error {
+			return errDeclined
+		},
+	})
+	require.NoError(err)
+	require.True(p.Empty())
+}
@@ -0,0 +1,235 @@
+// SPDX-FileCopyrightText: 2023 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+// Package manifest provisions cards from a declarative list of credentials.
+package manifest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	ykoath "cunicu.li/go-ykoath/v2"
+)
+
+var (
+	ErrInvalidManifest = errors.New("invalid manifest")
+	ErrInvalidSecret   = errors.New("invalid secret reference")
+	ErrSecretNotFound  = errors.New("secret not found")
+	ErrDuplicateName   = errors.New("duplicate name")
+)
+
+// Manifest lists the credentials which should be present on a card.
+//
+// Example:
+//
+//	{
+//	  "credentials": [
+//	    {
+//	      "name": "ci@example.com",
+//	      "issuer": "Example",
+//	      "algorithm": "SHA256",
+//	      "digits": 8,
+//	      "touch": true,
+//	      "secret": { "env": "EXAMPLE_TOTP_SECRET" }
+//	    }
+//	  ]
+//	}
+type Manifest struct {
+	Credentials []Credential `json:"credentials"`
+
+	// dir is the directory against which relative secret files are resolved
+	dir string
+}
+
+// Credential describes a single credential of a manifest.
+// Omitted fields default to a TOTP credential using HMAC-SHA1,
+// six digits and a period of 30 seconds.
+type Credential struct {
+	Name      string `json:"name"`
+	Issuer    string `json:"issuer,omitempty"`
+	Type      string `json:"type,omitempty"`
+	Algorithm string `json:"algorithm,omitempty"`
+	Digits    int    `json:"digits,omitempty"`
+	Period    int    `json:"period,omitempty"`
+	Counter   uint32 `json:"counter,omitempty"`
+	Touch     bool   `json:"touch,omitempty"`
+	Secret    Secret `json:"secret"`
+}
+
+// Secret references the Base32 encoded secret of a credential.
+// Exactly one of its fields must be set.
+type Secret struct {
+	// Env is the name of an environment variable which holds the secret.
+	Env string `json:"env,omitempty"`
+
+	// File is the path of a file which holds the secret.
+	// Relative paths are resolved against the directory of the manifest.
+	File string `json:"file,omitempty"`
+
+	// Base32 is the secret itself.
+	Base32 string `json:"base32,omitempty"`
+}
+
+// Parse decodes a manifest from its JSON representation.
+// Relative secret files are resolved against the working directory.
+func Parse(b []byte) (*Manifest, error) {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+
+	m := &Manifest{}
+	if err := dec.Decode(m); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidManifest, err)
+	}
+
+	return m, nil
+}
+
+// Load reads a manifest from a file.
+func Load(name string) (*Manifest, error) {
+	b, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+
+	m, err := Parse(b)
+	if err != nil {
+		return nil, err
+	}
+
+	m.dir = filepath.Dir(name)
+
+	return m, nil
+}
+
+// Resolve looks up the secrets of all credentials and validates them.
+// All credentials are checked before an error is returned.
+func (m *Manifest) Resolve() ([]*ykoath.CredentialData, error) {
+	var errs []error
+
+	ds := []*ykoath.CredentialData{}
+	names := map[string]bool{}
+
+	for i := range m.Credentials {
+		c := &m.Credentials[i]
+
+		d, err := m.resolve(c)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("credential %d (%s): %w", i, c.Name, err))
+			continue
+		}
+
+		name := d.ID.String()
+		if names[name] {
+			errs = append(errs, fmt.Errorf("credential %d: %w: %s", i, ErrDuplicateName, name))
+			continue
+		}
+
+		names[name] = true
+		ds = append(ds, d)
+	}
+
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	return ds, nil
+}
+
+func (m *Manifest) resolve(c *Credential) (*ykoath.CredentialData, error) {
+	d := &ykoath.CredentialData{
+		ID: ykoath.CredentialID{
+			Issuer:  c.Issuer,
+			Account: c.Name,
+		},
+		Digits:  c.Digits,
+		Touch:   c.Touch,
+		Counter: c.Counter,
+	}
+
+	if d.Digits == 0 {
+		d.Digits = ykoath.MinDigits
+	}
+
+	var err error
+
+	d.Type = ykoath.Totp
+	if c.Type != "" {
+		if d.Type, err = ykoath.ParseType(c.Type); err != nil {
+			return nil, err
+		}
+	}
+
+	if d.Type == ykoath.Totp {
+		d.ID.Period = time.Duration(c.Period) * time.Second
+
+		if d.ID.Period == 0 {
+			d.ID.Period = ykoath.DefaultTimeStep
+		}
+	}
+
+	if d.Algorithm, err = ykoath.ParseAlgorithm(c.Algorithm); err != nil {
+		return nil, err
+	}
+
+	if d.Secret, err = m.secret(&c.Secret); err != nil {
+		return nil, err
+	}
+
+	if err := d.Validate(); err != nil {
+		return nil, err
+	}
+
+	return d, nil
+}
+
+// secret looks up and decodes the referenced secret
+func (m *Manifest) secret(s *Secret) ([]byte, error) {
+	refs := 0
+	for _, r := range []string{s.Env, s.File, s.Base32} {
+		if r != "" {
+			refs++
+		}
+	}
+
+	if refs != 1 {
+		return nil, fmt.Errorf("%w: exactly one of env, file or base32 must be set", ErrInvalidSecret)
+	}
+
+	var value string
+
+	switch {
+	case s.Env != "":
+		var ok bool
+		if value, ok = os.LookupEnv(s.Env); !ok {
+			return nil, fmt.Errorf("%w: environment variable %s is not set", ErrSecretNotFound, s.Env)
+		}
+
+	case s.File != "":
+		name := s.File
+		if !filepath.IsAbs(name) && m.dir != "" {
+			name = filepath.Join(m.dir, name)
+		}
+
+		b, err := os.ReadFile(name)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrSecretNotFound, err)
+		}
+
+		value = string(b)
+
+	default:
+		value = s.Base32
+	}
+
+	secret, err := ykoath.DecodeSecret(value)
+	if err != nil || len(secret) == 0 {
+		return nil, fmt.Errorf("%w: not a Base32 encoded secret", ykoath.ErrInvalidSecret)
+	}
+
+	return secret, nil
+}
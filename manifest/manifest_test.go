@@ -0,0 +1,93 @@
+// SPDX-FileCopyrightText: 2023 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package manifest_test
+
+import (
+	"encoding/base32"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	ykoath "cunicu.li/go-ykoath/v2"
+	"cunicu.li/go-ykoath/v2/manifest"
+)
+
+func TestResolve(t *testing.T) {
+	require := require.New(t)
+
+	t.Setenv("YKOATH_TEST_SECRET", "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ")
+
+	m, err := manifest.Load("testdata/manifest.json")
+	require.NoError(err)
+
+	ds, err := m.Resolve()
+	require.NoError(err)
+	require.Len(ds, 3)
+
+	require.Equal(&ykoath.CredentialData{
+		ID: ykoath.CredentialID{
+			Period:  ykoath.DefaultTimeStep,
+			Issuer:  "Example",
+			Account: "ci@example.com",
+		},
+		Algorithm: ykoath.HmacSha1,
+		Type:      ykoath.Totp,
+		Digits:    6,
+		Secret:    []byte("12345678901234567890"),
+	}, ds[0])
+
+	// Secrets files are relative to the manifest
+	secret, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString("5OM4WOOGPLQEF6UGN3CPEOOLWU")
+	require.NoError(err)
+
+	require.Equal(&ykoath.CredentialData{
+		ID: ykoath.CredentialID{
+			Period:  60 * time.Second,
+			Issuer:  "SPDX",
+			Account: "deploy",
+		},
+		Algorithm: ykoath.HmacSha256,
+		Type:      ykoath.Totp,
+		Digits:    8,
+		Touch:     true,
+		Secret:    secret,
+	}, ds[1])
+
+	require.Equal(&ykoath.CredentialData{
+		ID: ykoath.CredentialID{
+			Account: "backup",
+		},
+		Algorithm: ykoath.HmacSha512,
+		Type:      ykoath.Hotp,
+		Digits:    6,
+		Counter:   42,
+		Secret:    []byte("12345678901234567890"),
+	}, ds[2])
+}
+
+func TestResolveInvalid(t *testing.T) {
+	require := require.New(t)
+
+	_, err := manifest.Parse([]byte(`{"credentials": [{"name": "a", "unknown": true}]}`))
+	require.ErrorIs(err, manifest.ErrInvalidManifest)
+
+	m, err := manifest.Parse([]byte(`{
+		"credentials": [
+			{ "name": "missing", "secret": { "env": "YKOATH_TEST_UNSET" } },
+			{ "name": "ambiguous", "secret": { "env": "A", "base32": "GEZDGNBV" } },
+			{ "name": "short", "digits": 4, "secret": { "base32": "GEZDGNBV" } },
+			{ "name": "twice", "secret": { "base32": "GEZDGNBV" } },
+			{ "name": "twice", "secret": { "base32": "GEZDGNBV" } }
+		]
+	}`))
+	require.NoError(err)
+
+	// All problems are reported at once
+	_, err = m.Resolve()
+	require.ErrorIs(err, manifest.ErrSecretNotFound)
+	require.ErrorIs(err, manifest.ErrInvalidSecret)
+	require.ErrorIs(err, ykoath.ErrInvalidDigits)
+	require.ErrorIs(err, manifest.ErrDuplicateName)
+}
@@ -0,0 +1,423 @@
+// SPDX-FileCopyrightText: 2023 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package migrate
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/scrypt"
+
+	ykoath "cunicu.li/go-ykoath/v2"
+)
+
+// Aegis stores its vault as JSON which is optionally encrypted by a master key.
+// The master key itself is wrapped by one or more key slots.
+// See: https://github.com/beemdevelopment/Aegis/blob/master/docs/vault.md
+
+var (
+	ErrPasswordRequired = errors.New("password required")
+	ErrWrongPassword    = errors.New("wrong password")
+)
+
+const (
+	aegisVersion   = 1
+	aegisDBVersion = 3
+
+	aegisSlotPassword = 1
+
+	aegisSteamDigits = 5
+)
+
+// AegisScryptParams are the scrypt parameters used for the password slot of encrypted vaults
+var AegisScryptParams = struct { //nolint:gochecknoglobals
+	N, R, P int
+}{
+	N: 1 << 15,
+	R: 8,
+	P: 1,
+}
+
+type hexBytes []byte
+
+func (h hexBytes) MarshalText() ([]byte, error) {
+	return []byte(hex.EncodeToString(h)), nil
+}
+
+func (h *hexBytes) UnmarshalText(b []byte) (err error) {
+	*h, err = hex.DecodeString(string(b))
+	return err
+}
+
+type aegisKeyParams struct {
+	Nonce hexBytes `json:"nonce"`
+	Tag   hexBytes `json:"tag"`
+}
+
+type aegisSlot struct {
+	Type      int            `json:"type"`
+	UUID      string         `json:"uuid"`
+	Key       hexBytes       `json:"key"`
+	KeyParams aegisKeyParams `json:"key_params"`
+	N         int            `json:"n,omitempty"`
+	R         int            `json:"r,omitempty"`
+	P         int            `json:"p,omitempty"`
+	Salt      hexBytes       `json:"salt,omitempty"`
+}
+
+type aegisHeader struct {
+	Slots  []aegisSlot     `json:"slots"`
+	Params *aegisKeyParams `json:"params"`
+}
+
+type aegisVault struct {
+	Version int             `json:"version"`
+	Header  aegisHeader     `json:"header"`
+	DB      json.RawMessage `json:"db"`
+}
+
+type aegisInfo struct {
+	Secret  string `json:"secret"`
+	Algo    string `json:"algo"`
+	Digits  int    `json:"digits"`
+	Period  int    `json:"period,omitempty"`
+	Counter uint64 `json:"counter,omitempty"`
+}
+
+type aegisEntry struct {
+	Type   string    `json:"type"`
+	UUID   string    `json:"uuid"`
+	Name   string    `json:"name"`
+	Issuer string    `json:"issuer"`
+	Note   string    `json:"note"`
+	Info   aegisInfo `json:"info"`
+}
+
+type aegisDB struct {
+	Version int          `json:"version"`
+	Entries []aegisEntry `json:"entries"`
+}
+
+// DecodeAegis decodes an Aegis vault export.
+// The password is only required for encrypted vaults.
+func DecodeAegis(b []byte, password []byte) (*Result, error) {
+	var vault aegisVault
+	if err := json.Unmarshal(b, &vault); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidExport, err)
+	}
+
+	if vault.Version != aegisVersion {
+		return nil, fmt.Errorf("%w: unsupported version %d", ErrInvalidExport, vault.Version)
+	}
+
+	data := []byte(vault.DB)
+
+	if vault.Header.Params != nil {
+		if password == nil {
+			return nil, ErrPasswordRequired
+		}
+
+		var enc string
+		if err := json.Unmarshal(vault.DB, &enc); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidExport, err)
+		}
+
+		ct, err := base64.StdEncoding.DecodeString(enc)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidExport, err)
+		}
+
+		key, err := vault.Header.masterKey(password)
+		if err != nil {
+			return nil, err
+		}
+
+		if data, err = aegisOpen(key, vault.Header.Params, ct); err != nil {
+			return nil, fmt.Errorf("%w: failed to decrypt vault: %w", ErrInvalidExport, err)
+		}
+	}
+
+	var db aegisDB
+	if err := json.Unmarshal(data, &db); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidExport, err)
+	}
+
+	r := &Result{}
+	for _, e := range db.Entries {
+		r.addAegis(&e)
+	}
+
+	return r, nil
+}
+
+// EncodeAegis encodes credentials into an Aegis vault.
+// The vault is encrypted if a password is given.
+// TOTP credentials with the issuer "Steam" are exported as Steam entries.
+func EncodeAegis(creds []ykoath.CredentialData, password []byte) ([]byte, error) {
+	db := aegisDB{
+		Version: aegisDBVersion,
+		Entries: []aegisEntry{},
+	}
+
+	for _, d := range creds {
+		e, err := encodeAegisEntry(&d)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", d.ID, err)
+		}
+
+		db.Entries = append(db.Entries, *e)
+	}
+
+	data, err := json.Marshal(db)
+	if err != nil {
+		return nil, err
+	}
+
+	vault := aegisVault{
+		Version: aegisVersion,
+		DB:      data,
+	}
+
+	if password != nil {
+		if vault.Header, vault.DB, err = aegisSeal(password, data); err != nil {
+			return nil, err
+		}
+	}
+
+	return json.MarshalIndent(vault, "", "    ")
+}
+
+func (r *Result) addAegis(e *aegisEntry) {
+	name := e.Name
+	if e.Issuer != "" {
+		name = e.Issuer + ":" + e.Name
+	}
+
+	d := ykoath.CredentialData{
+		ID: ykoath.CredentialID{
+			Issuer:  e.Issuer,
+			Account: e.Name,
+		},
+		Digits: e.Info.Digits,
+	}
+
+	alg, err := ykoath.ParseAlgorithm(e.Info.Algo)
+	if err != nil {
+		r.skip(name, fmt.Errorf("%w: algorithm %s", ErrUnsupported, e.Info.Algo))
+		return
+	}
+
+	d.Algorithm = alg
+
+	switch e.Type {
+	case "totp":
+		d.Type = ykoath.Totp
+		d.ID.Period = time.Duration(e.Info.Period) * time.Second
+
+	case "hotp":
+		if err := setCounter(&d, e.Info.Counter); err != nil {
+			r.skip(name, err)
+			return
+		}
+
+	case "steam":
+		// See ykoath.CredentialID.IsSteam
+		d.Type = ykoath.Totp
+		d.ID.Period = time.Duration(e.Info.Period) * time.Second
+		d.ID.Issuer = ykoath.SteamIssuer
+		d.Digits = ykoath.MinDigits
+
+	default:
+		r.skip(name, fmt.Errorf("%w: type %s", ErrUnsupported, e.Type))
+		return
+	}
+
+	if d.Type == ykoath.Totp && d.ID.Period == 0 {
+		d.ID.Period = ykoath.DefaultTimeStep
+	}
+
+	secret, err := ykoath.DecodeSecret(e.Info.Secret)
+	if err != nil {
+		r.skip(name, fmt.Errorf("%w: %w", ykoath.ErrInvalidSecret, err))
+		return
+	}
+
+	d.Secret = secret
+
+	r.add(name, d)
+}
+
+func encodeAegisEntry(d *ykoath.CredentialData) (*aegisEntry, error) {
+	e := &aegisEntry{
+		UUID:   newUUID(),
+		Name:   d.ID.Account,
+		Issuer: d.ID.Issuer,
+		Info: aegisInfo{
+			Secret: encodeBase32(d.Secret),
+			Algo:   strings.TrimPrefix(d.Algorithm.String(), "HMAC-"),
+			Digits: d.Digits,
+		},
+	}
+
+	switch d.Type {
+	case ykoath.Totp:
+		e.Type = "totp"
+		e.Info.Period = int(d.ID.Period / time.Second)
+		if e.Info.Period == 0 {
+			e.Info.Period = int(ykoath.DefaultTimeStep / time.Second)
+		}
+
+		if d.ID.Issuer == ykoath.SteamIssuer {
+			e.Type = "steam"
+			e.Info.Digits = aegisSteamDigits
+		}
+
+	case ykoath.Hotp:
+		e.Type = "hotp"
+		e.Info.Counter = uint64(d.Counter)
+
+	default:
+		return nil, fmt.Errorf("%w: type %s", ErrUnsupported, d.Type)
+	}
+
+	if d.Algorithm.Hash() == nil {
+		return nil, fmt.Errorf("%w: algorithm %s", ErrUnsupported, d.Algorithm)
+	}
+
+	return e, nil
+}
+
+// masterKey decrypts the master key using the first password slot which matches the password
+func (h *aegisHeader) masterKey(password []byte) ([]byte, error) {
+	for _, slot := range h.Slots {
+		if slot.Type != aegisSlotPassword {
+			continue
+		}
+
+		key, err := scrypt.Key(password, slot.Salt, slot.N, slot.R, slot.P, 32)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidExport, err)
+		}
+
+		if masterKey, err := aegisOpen(key, &slot.KeyParams, slot.Key); err == nil {
+			return masterKey, nil
+		}
+	}
+
+	return nil, ErrWrongPassword
+}
+
+// aegisSeal encrypts the database with a new master key which is wrapped by a password slot
+func aegisSeal(password, data []byte) (aegisHeader, json.RawMessage, error) {
+	masterKey := make([]byte, 32)
+	salt := make([]byte, 32)
+
+	for _, b := range [][]byte{masterKey, salt} {
+		if _, err := rand.Read(b); err != nil {
+			return aegisHeader{}, nil, err
+		}
+	}
+
+	key, err := scrypt.Key(password, salt, AegisScryptParams.N, AegisScryptParams.R, AegisScryptParams.P, 32)
+	if err != nil {
+		return aegisHeader{}, nil, err
+	}
+
+	wrapped, keyParams, err := aegisEncrypt(key, masterKey)
+	if err != nil {
+		return aegisHeader{}, nil, err
+	}
+
+	ct, params, err := aegisEncrypt(masterKey, data)
+	if err != nil {
+		return aegisHeader{}, nil, err
+	}
+
+	db, err := json.Marshal(base64.StdEncoding.EncodeToString(ct))
+	if err != nil {
+		return aegisHeader{}, nil, err
+	}
+
+	return aegisHeader{
+		Slots: []aegisSlot{
+			{
+				Type:      aegisSlotPassword,
+				UUID:      newUUID(),
+				Key:       wrapped,
+				KeyParams: *keyParams,
+				N:         AegisScryptParams.N,
+				R:         AegisScryptParams.R,
+				P:         AegisScryptParams.P,
+				Salt:      salt,
+			},
+		},
+		Params: params,
+	}, db, nil
+}
+
+// aegisOpen decrypts data using AES-256-GCM with the tag stored separately
+func aegisOpen(key []byte, params *aegisKeyParams, ct []byte) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(params.Nonce) != aead.NonceSize() {
+		return nil, fmt.Errorf("invalid nonce size %d", len(params.Nonce))
+	}
+
+	return aead.Open(nil, params.Nonce, append(ct, params.Tag...), nil)
+}
+
+// aegisEncrypt encrypts data using AES-256-GCM and returns the tag separately
+func aegisEncrypt(key, data []byte) ([]byte, *aegisKeyParams, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, nil, err
+	}
+
+	ct := aead.Seal(nil, nonce, data, nil)
+	l := len(ct) - aead.Overhead()
+
+	return ct[:l], &aegisKeyParams{
+		Nonce: nonce,
+		Tag:   ct[l:],
+	}, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	blk, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(blk)
+}
+
+func newUUID() string {
+	var u [16]byte
+	if _, err := rand.Read(u[:]); err != nil {
+		panic(err)
+	}
+
+	u[6] = u[6]&0x0f | 0x40 // Version 4
+	u[8] = u[8]&0x3f | 0x80 // Variant RFC 4122
+
+	h := hex.EncodeToString(u[:])
+
+	return h[0:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:]
+}
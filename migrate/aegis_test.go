@@ -0,0 +1,125 @@
+// SPDX-FileCopyrightText: 2023 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package migrate_test
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	ykoath "cunicu.li/go-ykoath/v2"
+	"cunicu.li/go-ykoath/v2/migrate"
+)
+
+func TestDecodeAegis(t *testing.T) {
+	require := require.New(t)
+
+	b, err := os.ReadFile("testdata/aegis-plain.json")
+	require.NoError(err)
+
+	r, err := migrate.DecodeAegis(b, nil)
+	require.NoError(err)
+	require.Len(r.Credentials, 4)
+
+	require.Equal(ykoath.CredentialData{
+		ID: ykoath.CredentialID{
+			Period:  20 * time.Second,
+			Issuer:  "SPDX",
+			Account: "James",
+		},
+		Algorithm: ykoath.HmacSha256,
+		Type:      ykoath.Totp,
+		Digits:    7,
+		Secret:    fromBase32("5OM4WOOGPLQEF6UGN3CPEOOLWU"),
+	}, r.Credentials[1])
+
+	require.Equal(ykoath.Hotp, r.Credentials[2].Type)
+	require.EqualValues(50, r.Credentials[2].Counter)
+
+	// Steam entries are stored as TOTP credentials of the issuer "Steam"
+	require.Equal(ykoath.CredentialID{
+		Period:  ykoath.DefaultTimeStep,
+		Issuer:  "Steam",
+		Account: "Sophia",
+	}, r.Credentials[3].ID)
+
+	require.Len(r.Skipped, 3)
+	require.Equal("Legacy:Olivia", r.Skipped[0].Name)
+	require.ErrorIs(r.Skipped[0].Reason, migrate.ErrUnsupported)
+	require.Equal("Short:Emma", r.Skipped[1].Name)
+	require.ErrorIs(r.Skipped[1].Reason, ykoath.ErrInvalidDigits)
+	require.Equal("Yandex:Noah", r.Skipped[2].Name)
+	require.ErrorIs(r.Skipped[2].Reason, migrate.ErrUnsupported)
+}
+
+func TestDecodeAegisDefaultPeriod(t *testing.T) {
+	require := require.New(t)
+
+	b := []byte(`{
+		"version": 1,
+		"header": { "slots": null, "params": null },
+		"db": {
+			"version": 3,
+			"entries": [
+				{
+					"type": "totp",
+					"name": "Mason",
+					"issuer": "Deno",
+					"info": { "secret": "4SJHB4GSD43FZBAI7C2HLRJGPQ", "algo": "SHA1", "digits": 6 }
+				},
+				{
+					"type": "steam",
+					"name": "Sophia",
+					"info": { "secret": "JRZCL47CMXVOQMNPZR2F7J4RGI", "algo": "SHA1", "digits": 5 }
+				}
+			]
+		}
+	}`)
+
+	r, err := migrate.DecodeAegis(b, nil)
+	require.NoError(err)
+	require.Len(r.Credentials, 2)
+
+	// Entries without a period use the default one
+	require.Equal(ykoath.DefaultTimeStep, r.Credentials[0].ID.Period)
+	require.True(r.Credentials[1].ID.IsSteam())
+}
+
+func TestAegisRoundTrip(t *testing.T) {
+	require := require.New(t)
+
+	// Speed up tests
+	params := migrate.AegisScryptParams
+	migrate.AegisScryptParams.N = 1 << 10
+
+	t.Cleanup(func() {
+		migrate.AegisScryptParams = params
+	})
+
+	b, err := os.ReadFile("testdata/aegis-plain.json")
+	require.NoError(err)
+
+	r, err := migrate.DecodeAegis(b, nil)
+	require.NoError(err)
+
+	for _, password := range [][]byte{nil, []byte("test")} {
+		b, err := migrate.EncodeAegis(r.Credentials, password)
+		require.NoError(err)
+
+		if password != nil {
+			_, err = migrate.DecodeAegis(b, nil)
+			require.ErrorIs(err, migrate.ErrPasswordRequired)
+
+			_, err = migrate.DecodeAegis(b, []byte("wrong"))
+			require.ErrorIs(err, migrate.ErrWrongPassword)
+		}
+
+		r2, err := migrate.DecodeAegis(b, password)
+		require.NoError(err)
+		require.Empty(r2.Skipped)
+		require.Equal(r.Credentials, r2.Credentials)
+	}
+}
@@ -0,0 +1,178 @@
+// SPDX-FileCopyrightText: 2023 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package migrate
+
+import (
+	"crypto/sha1" //nolint:gosec
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/pbkdf2"
+
+	ykoath "cunicu.li/go-ykoath/v2"
+)
+
+// andOTP exports its accounts as a JSON array which is optionally encrypted.
+// Encrypted backups (.json.aes) are prefixed by the number of PBKDF2 iterations,
+// the salt and the nonce for AES-256-GCM.
+// Backups of older versions are encrypted with the SHA-256 hash of the password
+// and are only prefixed by the nonce.
+// See: https://github.com/andOTP/andOTP/wiki/Backup-formats
+
+const (
+	andOTPIterationsSize = 4
+	andOTPSaltSize       = 12
+	andOTPNonceSize      = 12
+	andOTPKeySize        = 32
+)
+
+type andOTPEntry struct {
+	Secret    string `json:"secret"`
+	Issuer    string `json:"issuer"`
+	Label     string `json:"label"`
+	Digits    int    `json:"digits"`
+	Type      string `json:"type"`
+	Algorithm string `json:"algorithm"`
+	Period    int    `json:"period,omitempty"`
+	Counter   uint64 `json:"counter,omitempty"`
+}
+
+// DecodeAndOTP decodes a plain or encrypted andOTP backup.
+// The password is only required for encrypted backups.
+func DecodeAndOTP(b []byte, password []byte) (*Result, error) {
+	data := b
+
+	// Encrypted backups are binary and might start with any byte
+	if !json.Valid(b) {
+		if password == nil {
+			return nil, ErrPasswordRequired
+		}
+
+		var err error
+		if data, err = andOTPOpen(b, password); err != nil {
+			return nil, err
+		}
+	}
+
+	var entries []andOTPEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidExport, err)
+	}
+
+	r := &Result{}
+	for _, e := range entries {
+		r.addAndOTP(&e)
+	}
+
+	return r, nil
+}
+
+// andOTPOpen decrypts an encrypted backup.
+// The legacy format is tried if the current one can not be decrypted.
+func andOTPOpen(b []byte, password []byte) ([]byte, error) {
+	const headerSize = andOTPIterationsSize + andOTPSaltSize + andOTPNonceSize
+
+	if len(b) > headerSize {
+		iter := binary.BigEndian.Uint32(b[:andOTPIterationsSize])
+		salt := b[andOTPIterationsSize : andOTPIterationsSize+andOTPSaltSize]
+		nonce := b[andOTPIterationsSize+andOTPSaltSize : headerSize]
+
+		if iter > 0 && iter <= 1<<24 {
+			key := pbkdf2.Key(password, salt, int(iter), andOTPKeySize, sha1.New)
+			if data, err := andOTPDecrypt(key, nonce, b[headerSize:]); err == nil {
+				return data, nil
+			}
+		}
+	}
+
+	if len(b) > andOTPNonceSize {
+		key := sha256.Sum256(password)
+		if data, err := andOTPDecrypt(key[:], b[:andOTPNonceSize], b[andOTPNonceSize:]); err == nil {
+			return data, nil
+		}
+	}
+
+	return nil, ErrWrongPassword
+}
+
+func andOTPDecrypt(key, nonce, ct []byte) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return aead.Open(nil, nonce, ct, nil)
+}
+
+func (r *Result) addAndOTP(e *andOTPEntry) {
+	d := ykoath.CredentialData{
+		ID: ykoath.CredentialID{
+			Issuer:  e.Issuer,
+			Account: e.Label,
+		},
+		Digits: e.Digits,
+	}
+
+	// Older versions store the issuer as part of the label
+	if issuer, account, ok := strings.Cut(e.Label, ":"); ok && d.ID.Issuer == "" {
+		d.ID.Issuer = issuer
+		d.ID.Account = strings.TrimLeft(account, " ")
+	}
+
+	name := d.ID.Account
+	if d.ID.Issuer != "" {
+		name = d.ID.Issuer + ":" + d.ID.Account
+	}
+
+	alg, err := ykoath.ParseAlgorithm(e.Algorithm)
+	if err != nil {
+		r.skip(name, fmt.Errorf("%w: algorithm %s", ErrUnsupported, e.Algorithm))
+		return
+	}
+
+	d.Algorithm = alg
+
+	switch strings.ToUpper(e.Type) {
+	case "TOTP":
+		d.Type = ykoath.Totp
+		d.ID.Period = time.Duration(e.Period) * time.Second
+
+	case "HOTP":
+		if err := setCounter(&d, e.Counter); err != nil {
+			r.skip(name, err)
+			return
+		}
+
+	case "STEAM":
+		// See ykoath.CredentialID.IsSteam
+		d.Type = ykoath.Totp
+		d.ID.Period = time.Duration(e.Period) * time.Second
+		d.ID.Issuer = ykoath.SteamIssuer
+		d.Digits = ykoath.MinDigits
+
+		name = ykoath.SteamIssuer + ":" + d.ID.Account
+
+	default:
+		r.skip(name, fmt.Errorf("%w: type %s", ErrUnsupported, e.Type))
+		return
+	}
+
+	if d.Type == ykoath.Totp && d.ID.Period == 0 {
+		d.ID.Period = ykoath.DefaultTimeStep
+	}
+
+	secret, err := ykoath.DecodeSecret(e.Secret)
+	if err != nil {
+		r.skip(name, fmt.Errorf("%w: %w", ykoath.ErrInvalidSecret, err))
+		return
+	}
+
+	d.Secret = secret
+
+	r.add(name, d)
+}
@@ -0,0 +1,142 @@
+// SPDX-FileCopyrightText: 2023 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package migrate_test
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha1" //nolint:gosec
+	"crypto/sha256"
+	"encoding/binary"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/pbkdf2"
+
+	ykoath "cunicu.li/go-ykoath/v2"
+	"cunicu.li/go-ykoath/v2/migrate"
+)
+
+func TestDecodeAndOTP(t *testing.T) {
+	require := require.New(t)
+
+	b, err := os.ReadFile("testdata/andotp-plain.json")
+	require.NoError(err)
+
+	r, err := migrate.DecodeAndOTP(b, nil)
+	require.NoError(err)
+	require.Len(r.Credentials, 4)
+
+	require.Equal(ykoath.CredentialData{
+		ID: ykoath.CredentialID{
+			Period:  60 * time.Second,
+			Issuer:  "SPDX",
+			Account: "James",
+		},
+		Algorithm: ykoath.HmacSha256,
+		Type:      ykoath.Totp,
+		Digits:    8,
+		Secret:    fromBase32("5OM4WOOGPLQEF6UGN3CPEOOLWU"),
+	}, r.Credentials[1])
+
+	// The issuer of older backups is part of the label
+	require.Equal(ykoath.CredentialID{
+		Issuer:  "Legacy",
+		Account: "bob",
+	}, r.Credentials[2].ID)
+	require.Equal(ykoath.Hotp, r.Credentials[2].Type)
+	require.Equal(ykoath.HmacSha512, r.Credentials[2].Algorithm)
+	require.EqualValues(42, r.Credentials[2].Counter)
+
+	// Steam entries are stored as TOTP credentials of the issuer "Steam"
+	require.Equal(ykoath.CredentialID{
+		Period:  ykoath.DefaultTimeStep,
+		Issuer:  "Steam",
+		Account: "gaben",
+	}, r.Credentials[3].ID)
+
+	require.Len(r.Skipped, 2)
+	require.Equal("Mobile:carol", r.Skipped[0].Name)
+	require.ErrorIs(r.Skipped[0].Reason, migrate.ErrUnsupported)
+	require.Equal("Short:dave", r.Skipped[1].Name)
+	require.ErrorIs(r.Skipped[1].Reason, ykoath.ErrInvalidDigits)
+}
+
+func TestDecodeAndOTPEncrypted(t *testing.T) {
+	require := require.New(t)
+
+	plain, err := os.ReadFile("testdata/andotp-plain.json")
+	require.NoError(err)
+
+	expected, err := migrate.DecodeAndOTP(plain, nil)
+	require.NoError(err)
+
+	password := []byte("test")
+
+	for _, b := range [][]byte{
+		encryptAndOTP(t, password, plain),
+		encryptAndOTPLegacy(t, password, plain),
+	} {
+		_, err = migrate.DecodeAndOTP(b, nil)
+		require.ErrorIs(err, migrate.ErrPasswordRequired)
+
+		_, err = migrate.DecodeAndOTP(b, []byte("wrong"))
+		require.ErrorIs(err, migrate.ErrWrongPassword)
+
+		r, err := migrate.DecodeAndOTP(b, password)
+		require.NoError(err)
+		require.Equal(expected, r)
+	}
+
+	// Legacy backups start with a random nonce which might look like plain JSON
+	key := sha256.Sum256(password)
+	nonce := []byte("[ \t\n01234567")
+	b := append(nonce, seal(t, key[:], nonce, plain)...)
+
+	r, err := migrate.DecodeAndOTP(b, password)
+	require.NoError(err)
+	require.Equal(expected, r)
+}
+
+func encryptAndOTP(t *testing.T, password, data []byte) []byte {
+	salt := make([]byte, 12)
+	nonce := make([]byte, 12)
+
+	for _, b := range [][]byte{salt, nonce} {
+		_, err := rand.Read(b)
+		require.NoError(t, err)
+	}
+
+	iter := 1000
+	key := pbkdf2.Key(password, salt, iter, 32, sha1.New)
+
+	b := binary.BigEndian.AppendUint32(nil, uint32(iter))
+	b = append(b, salt...)
+	b = append(b, nonce...)
+
+	return append(b, seal(t, key, nonce, data)...)
+}
+
+func encryptAndOTPLegacy(t *testing.T, password, data []byte) []byte {
+	nonce := make([]byte, 12)
+	_, err := rand.Read(nonce)
+	require.NoError(t, err)
+
+	key := sha256.Sum256(password)
+
+	return append(nonce, seal(t, key[:], nonce, data)...)
+}
+
+func seal(t *testing.T, key, nonce, data []byte) []byte {
+	blk, err := aes.NewCipher(key)
+	require.NoError(t, err)
+
+	aead, err := cipher.NewGCM(blk)
+	require.NoError(t, err)
+
+	return aead.Seal(nil, nonce, data, nil)
+}
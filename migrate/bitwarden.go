@@ -0,0 +1,56 @@
+// SPDX-FileCopyrightText: 2023 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package migrate
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Bitwarden exports its vault as a JSON document.
+// The TOTP seed of a login is stored in the field "totp".
+// See: https://bitwarden.com/help/condition-bitwarden-import/
+
+const bitwardenTypeLogin = 1
+
+type bitwardenLogin struct {
+	Username string `json:"username"`
+	TOTP     string `json:"totp"`
+}
+
+type bitwardenItem struct {
+	Type  int             `json:"type"`
+	Name  string          `json:"name"`
+	Login *bitwardenLogin `json:"login"`
+}
+
+type bitwardenExport struct {
+	Encrypted bool            `json:"encrypted"`
+	Items     []bitwardenItem `json:"items"`
+}
+
+// DecodeBitwarden decodes the TOTP credentials of a Bitwarden JSON export.
+// Items without a TOTP seed are ignored.
+// Password protected exports are not supported.
+func DecodeBitwarden(b []byte) (*Result, error) {
+	var export bitwardenExport
+	if err := json.Unmarshal(b, &export); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidExport, err)
+	}
+
+	if export.Encrypted {
+		return nil, fmt.Errorf("%w: encrypted Bitwarden exports", ErrUnsupported)
+	}
+
+	r := &Result{}
+	for _, i := range export.Items {
+		if i.Type != bitwardenTypeLogin || i.Login == nil {
+			continue
+		}
+
+		r.addOTP(i.Name, i.Login.Username, i.Login.TOTP)
+	}
+
+	return r, nil
+}
@@ -0,0 +1,60 @@
+// SPDX-FileCopyrightText: 2023 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package migrate_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	ykoath "cunicu.li/go-ykoath/v2"
+	"cunicu.li/go-ykoath/v2/migrate"
+)
+
+func TestDecodeBitwarden(t *testing.T) {
+	require := require.New(t)
+
+	b, err := os.ReadFile("testdata/bitwarden.json")
+	require.NoError(err)
+
+	r, err := migrate.DecodeBitwarden(b)
+	require.NoError(err)
+	require.Len(r.Credentials, 3)
+
+	require.Equal(ykoath.CredentialData{
+		ID: ykoath.CredentialID{
+			Period:  ykoath.DefaultTimeStep,
+			Issuer:  "Example",
+			Account: "alice@example.com",
+		},
+		Algorithm: ykoath.HmacSha1,
+		Type:      ykoath.Totp,
+		Digits:    6,
+		Secret:    []byte("12345678901234567890"),
+	}, r.Credentials[0])
+
+	// Bare secrets are named after the item and its username
+	require.Equal(ykoath.CredentialData{
+		ID: ykoath.CredentialID{
+			Period:  ykoath.DefaultTimeStep,
+			Issuer:  "SPDX",
+			Account: "James",
+		},
+		Algorithm: ykoath.HmacSha1,
+		Type:      ykoath.Totp,
+		Digits:    6,
+		Secret:    fromBase32("5OM4WOOGPLQEF6UGN3CPEOOLWU"),
+	}, r.Credentials[1])
+
+	require.True(r.Credentials[2].ID.IsSteam())
+	require.Equal("gaben", r.Credentials[2].ID.Account)
+
+	require.Len(r.Skipped, 1)
+	require.Equal("Broken:dave", r.Skipped[0].Name)
+	require.ErrorIs(r.Skipped[0].Reason, ykoath.ErrInvalidSecret)
+
+	_, err = migrate.DecodeBitwarden([]byte(`{"encrypted": true, "items": []}`))
+	require.ErrorIs(err, migrate.ErrUnsupported)
+}
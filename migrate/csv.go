@@ -0,0 +1,91 @@
+// SPDX-FileCopyrightText: 2023 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package migrate
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+)
+
+// Many password managers export their entries as CSV files with a header row.
+// The columns are identified by their names which differ between the
+// applications, e.g.:
+//
+//	KeePassXC:  "Group","Title","Username","Password","URL","Notes","TOTP",...
+//	1Password:  Title,Url,Username,Password,OTPAuth,Favorite,Archived,Tags,Notes
+//	Bitwarden:  folder,favorite,type,name,notes,fields,reprompt,login_uri,login_username,login_password,login_totp
+
+var ErrMissingColumn = errors.New("missing column")
+
+//nolint:gochecknoglobals
+var (
+	csvTitleColumns    = []string{"title", "name"}
+	csvUsernameColumns = []string{"username", "login_username", "user name"}
+	csvOTPColumns      = []string{"totp", "otpauth", "login_totp", "one-time password", "otp"}
+)
+
+// DecodeCSV decodes the TOTP credentials of a CSV export of a
+// password manager like KeePassXC, 1Password or Bitwarden.
+// Rows without a TOTP seed are ignored.
+func DecodeCSV(b []byte) (*Result, error) {
+	b = bytes.TrimPrefix(b, []byte("\xef\xbb\xbf")) // UTF-8 byte order mark
+
+	cr := csv.NewReader(bytes.NewReader(b))
+	cr.FieldsPerRecord = -1
+
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidExport, err)
+	}
+
+	title := csvColumn(header, csvTitleColumns)
+	username := csvColumn(header, csvUsernameColumns)
+	otp := csvColumn(header, csvOTPColumns)
+
+	if otp < 0 {
+		return nil, fmt.Errorf("%w: %w: TOTP", ErrInvalidExport, ErrMissingColumn)
+	}
+
+	r := &Result{}
+
+	for {
+		row, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidExport, err)
+		}
+
+		r.addOTP(csvField(row, title), csvField(row, username), csvField(row, otp))
+	}
+
+	return r, nil
+}
+
+// csvColumn returns the index of the first column of the header
+// which matches one of the names or -1 if none does.
+func csvColumn(header []string, names []string) int {
+	for _, n := range names {
+		if i := slices.IndexFunc(header, func(h string) bool {
+			return strings.EqualFold(strings.TrimSpace(h), n)
+		}); i >= 0 {
+			return i
+		}
+	}
+
+	return -1
+}
+
+func csvField(row []string, i int) string {
+	if i < 0 || i >= len(row) {
+		return ""
+	}
+
+	return row[i]
+}
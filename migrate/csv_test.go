@@ -0,0 +1,78 @@
+// SPDX-FileCopyrightText: 2023 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package migrate_test
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	ykoath "cunicu.li/go-ykoath/v2"
+	"cunicu.li/go-ykoath/v2/migrate"
+)
+
+func TestDecodeCSVKeePassXC(t *testing.T) {
+	require := require.New(t)
+
+	b, err := os.ReadFile("testdata/keepassxc.csv")
+	require.NoError(err)
+
+	r, err := migrate.DecodeCSV(b)
+	require.NoError(err)
+	require.Empty(r.Skipped)
+	require.Len(r.Credentials, 3)
+
+	require.Equal(ykoath.CredentialID{
+		Period:  ykoath.DefaultTimeStep,
+		Issuer:  "Example",
+		Account: "alice@example.com",
+	}, r.Credentials[0].ID)
+
+	// The title is used as issuer if the URI does not include one
+	require.Equal(ykoath.CredentialData{
+		ID: ykoath.CredentialID{
+			Period:  60 * time.Second,
+			Issuer:  "SPDX",
+			Account: "James",
+		},
+		Algorithm: ykoath.HmacSha256,
+		Type:      ykoath.Totp,
+		Digits:    8,
+		Secret:    fromBase32("5OM4WOOGPLQEF6UGN3CPEOOLWU"),
+	}, r.Credentials[1])
+
+	// Entries without label and username are named after their title
+	require.Equal(ykoath.CredentialID{
+		Account: "Counter",
+	}, r.Credentials[2].ID)
+	require.Equal(ykoath.Hotp, r.Credentials[2].Type)
+	require.EqualValues(42, r.Credentials[2].Counter)
+}
+
+func TestDecodeCSVOnePassword(t *testing.T) {
+	require := require.New(t)
+
+	b, err := os.ReadFile("testdata/1password.csv")
+	require.NoError(err)
+
+	r, err := migrate.DecodeCSV(b)
+	require.NoError(err)
+	require.Empty(r.Skipped)
+	require.Len(r.Credentials, 2)
+
+	require.Equal("Example:alice@example.com", r.Credentials[0].ID.String())
+
+	// Long labels are truncated instead of being rejected
+	require.Equal([]migrate.Truncated{
+		{
+			Name:     "A service with an exceptionally long name:somebody.with.a.long.a",
+			Original: "A service with an exceptionally long name:somebody.with.a.long.address@example.com",
+		},
+	}, r.Truncated)
+
+	_, err = migrate.DecodeCSV([]byte("Title,Username,Password\nExample,alice,secret\n"))
+	require.ErrorIs(err, migrate.ErrMissingColumn)
+}
@@ -0,0 +1,100 @@
+// SPDX-FileCopyrightText: 2023 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package migrate
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	ykoath "cunicu.li/go-ykoath/v2"
+)
+
+// FreeOTP+ exports its tokens as a JSON document.
+// The secrets are stored as arrays of signed bytes as serialized by Java.
+// See: https://github.com/helloworld1/FreeOTPPlus
+
+//nolint:tagliatelle
+type freeOTPToken struct {
+	Algo      string `json:"algo"`
+	Counter   uint64 `json:"counter"`
+	Digits    int    `json:"digits"`
+	IssuerExt string `json:"issuerExt"`
+	IssuerInt string `json:"issuerInt"`
+	Label     string `json:"label"`
+	Period    int    `json:"period"`
+	Secret    []int8 `json:"secret"`
+	Type      string `json:"type"`
+}
+
+type freeOTPExport struct {
+	Tokens []freeOTPToken `json:"tokens"`
+}
+
+// DecodeFreeOTP decodes a JSON export of FreeOTP+.
+func DecodeFreeOTP(b []byte) (*Result, error) {
+	var export freeOTPExport
+	if err := json.Unmarshal(b, &export); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidExport, err)
+	}
+
+	r := &Result{}
+	for _, t := range export.Tokens {
+		r.addFreeOTP(&t)
+	}
+
+	return r, nil
+}
+
+func (r *Result) addFreeOTP(t *freeOTPToken) {
+	d := ykoath.CredentialData{
+		ID: ykoath.CredentialID{
+			Issuer:  t.IssuerExt,
+			Account: t.Label,
+		},
+		Digits: t.Digits,
+	}
+
+	if d.ID.Issuer == "" {
+		d.ID.Issuer = t.IssuerInt
+	}
+
+	name := d.ID.String()
+
+	alg, err := ykoath.ParseAlgorithm(t.Algo)
+	if err != nil {
+		r.skip(name, fmt.Errorf("%w: algorithm %s", ErrUnsupported, t.Algo))
+		return
+	}
+
+	d.Algorithm = alg
+
+	switch strings.ToUpper(t.Type) {
+	case "TOTP":
+		d.Type = ykoath.Totp
+		d.ID.Period = time.Duration(t.Period) * time.Second
+
+	case "HOTP":
+		if err := setCounter(&d, t.Counter); err != nil {
+			r.skip(name, err)
+			return
+		}
+
+	default:
+		r.skip(name, fmt.Errorf("%w: type %s", ErrUnsupported, t.Type))
+		return
+	}
+
+	if d.Type == ykoath.Totp && d.ID.Period == 0 {
+		d.ID.Period = ykoath.DefaultTimeStep
+	}
+
+	d.Secret = make([]byte, len(t.Secret))
+	for i, s := range t.Secret {
+		d.Secret[i] = byte(s)
+	}
+
+	r.add(name, d)
+}
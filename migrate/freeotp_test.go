@@ -0,0 +1,53 @@
+// SPDX-FileCopyrightText: 2023 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package migrate_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	ykoath "cunicu.li/go-ykoath/v2"
+	"cunicu.li/go-ykoath/v2/migrate"
+)
+
+func TestDecodeFreeOTP(t *testing.T) {
+	require := require.New(t)
+
+	b, err := os.ReadFile("testdata/freeotp-plus.json")
+	require.NoError(err)
+
+	r, err := migrate.DecodeFreeOTP(b)
+	require.NoError(err)
+	require.Len(r.Credentials, 2)
+
+	require.Equal(ykoath.CredentialData{
+		ID: ykoath.CredentialID{
+			Period:  ykoath.DefaultTimeStep,
+			Issuer:  "Example",
+			Account: "alice@example.com",
+		},
+		Algorithm: ykoath.HmacSha1,
+		Type:      ykoath.Totp,
+		Digits:    6,
+		Secret:    []byte("12345678901234567890"),
+	}, r.Credentials[0])
+
+	// Secrets are serialized as signed bytes
+	require.Equal(ykoath.CredentialData{
+		ID: ykoath.CredentialID{
+			Account: "bob",
+		},
+		Algorithm: ykoath.HmacSha256,
+		Type:      ykoath.Hotp,
+		Digits:    8,
+		Counter:   7,
+		Secret:    []byte{0xff, 0x80, 0x00, 0x7f, 16, 32, 64, 1, 2, 3, 4, 5, 6, 7, 8, 9},
+	}, r.Credentials[1])
+
+	require.Len(r.Skipped, 1)
+	require.Equal("Short:dave", r.Skipped[0].Name)
+	require.ErrorIs(r.Skipped[0].Reason, ykoath.ErrInvalidDigits)
+}
@@ -0,0 +1,380 @@
+// SPDX-FileCopyrightText: 2023 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package migrate
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+
+	ykoath "cunicu.li/go-ykoath/v2"
+)
+
+// Google Authenticator exports its accounts as one or more
+// "otpauth-migration://offline?data=" URIs containing a protocol buffer.
+// See: https://github.com/dim13/otpauth/blob/master/migration/migration.proto
+
+var ErrIncompleteBatch = errors.New("incomplete batch")
+
+const (
+	googleScheme = "otpauth-migration"
+	googleHost   = "offline"
+
+	// GoogleBatchSize is the number of credentials per batch
+	// used by Google Authenticator for its QR codes.
+	GoogleBatchSize = 10
+)
+
+// Field numbers of the MigrationPayload message
+const (
+	googlePayloadOTPParameters = 1
+	googlePayloadVersion       = 2
+	googlePayloadBatchSize     = 3
+	googlePayloadBatchIndex    = 4
+	googlePayloadBatchID       = 5
+)
+
+// Field numbers of the OtpParameters message
+const (
+	googleOTPSecret    = 1
+	googleOTPName      = 2
+	googleOTPIssuer    = 3
+	googleOTPAlgorithm = 4
+	googleOTPDigits    = 5
+	googleOTPType      = 6
+	googleOTPCounter   = 7
+)
+
+// Enum values of the OtpParameters message
+const (
+	googleAlgorithmSHA1   = 1
+	googleAlgorithmSHA256 = 2
+	googleAlgorithmSHA512 = 3
+	googleAlgorithmMD5    = 4
+
+	googleDigitsSix   = 1
+	googleDigitsEight = 2
+
+	googleTypeHOTP = 1
+	googleTypeTOTP = 2
+)
+
+type googleBatch struct {
+	size    int
+	indices []int
+}
+
+// DecodeGoogleAuthenticator decodes the "otpauth-migration://" URIs of a
+// Google Authenticator export. All batches of an export must be passed.
+func DecodeGoogleAuthenticator(uris ...string) (*Result, error) {
+	r := &Result{}
+	batches := map[uint64]*googleBatch{}
+
+	for _, uri := range uris {
+		data, err := decodeGoogleURI(uri)
+		if err != nil {
+			return nil, err
+		}
+
+		fields, err := decodeProto(data)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidExport, err)
+		}
+
+		var (
+			batchID    uint64
+			batchIndex int
+			batchSize  = 1
+		)
+
+		for _, f := range fields {
+			switch f.Num {
+			case googlePayloadOTPParameters:
+				if err := r.addGoogle(f.Bytes); err != nil {
+					return nil, err
+				}
+
+			case googlePayloadBatchSize:
+				batchSize = int(f.Varint) //nolint:gosec
+
+			case googlePayloadBatchIndex:
+				batchIndex = int(f.Varint) //nolint:gosec
+
+			case googlePayloadBatchID:
+				batchID = f.Varint
+			}
+		}
+
+		if batchSize < 1 || batchIndex < 0 || batchIndex >= batchSize {
+			return nil, fmt.Errorf("%w: batch %d of %d", ErrInvalidExport, batchIndex+1, batchSize)
+		}
+
+		b, ok := batches[batchID]
+		if !ok {
+			b = &googleBatch{
+				size: batchSize,
+			}
+			batches[batchID] = b
+		} else if b.size != batchSize {
+			return nil, fmt.Errorf("%w: batch size %d differs from %d", ErrInvalidExport, batchSize, b.size)
+		}
+
+		if slices.Contains(b.indices, batchIndex) {
+			return nil, fmt.Errorf("%w: duplicate batch %d of %d", ErrInvalidExport, batchIndex+1, b.size)
+		}
+
+		b.indices = append(b.indices, batchIndex)
+	}
+
+	for _, b := range batches {
+		// A complete export consists of one URI per batch.
+		// The size is checked before it is used as loop bound as it is not trusted.
+		if b.size > len(uris) {
+			return nil, fmt.Errorf("%w: %d of %d batches given", ErrIncompleteBatch, len(b.indices), b.size)
+		}
+
+		for i := range b.size {
+			if !slices.Contains(b.indices, i) {
+				return nil, fmt.Errorf("%w: missing batch %d of %d", ErrIncompleteBatch, i+1, b.size)
+			}
+		}
+	}
+
+	return r, nil
+}
+
+// EncodeGoogleAuthenticator encodes credentials into "otpauth-migration://" URIs
+// which can be imported by Google Authenticator. Each URI contains up to batchSize
+// credentials. A batchSize of zero encodes all credentials into a single URI.
+func EncodeGoogleAuthenticator(creds []ykoath.CredentialData, batchSize int) ([]string, error) {
+	var params [][]byte
+
+	for _, d := range creds {
+		p, err := encodeGoogleOTPParameters(&d)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", d.ID, err)
+		}
+
+		params = append(params, p)
+	}
+
+	if batchSize <= 0 {
+		batchSize = max(len(params), 1)
+	}
+
+	var id [4]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return nil, fmt.Errorf("failed to generate batch ID: %w", err)
+	}
+
+	batchID := uint64(binary.BigEndian.Uint32(id[:]) >> 1)
+	batches := slices.Collect(slices.Chunk(params, batchSize))
+	if len(batches) == 0 {
+		batches = [][][]byte{nil}
+	}
+
+	uris := make([]string, 0, len(batches))
+
+	for idx, batch := range batches {
+		var data []byte
+
+		for _, p := range batch {
+			data = appendBytesField(data, googlePayloadOTPParameters, p)
+		}
+
+		data = appendVarintField(data, googlePayloadVersion, 1)
+		data = appendVarintField(data, googlePayloadBatchSize, uint64(len(batches)))
+		data = appendVarintField(data, googlePayloadBatchIndex, uint64(idx)) //nolint:gosec
+		data = appendVarintField(data, googlePayloadBatchID, batchID)
+
+		u := url.URL{
+			Scheme:   googleScheme,
+			Host:     googleHost,
+			RawQuery: url.Values{"data": {base64.StdEncoding.EncodeToString(data)}}.Encode(),
+		}
+
+		uris = append(uris, u.String())
+	}
+
+	return uris, nil
+}
+
+func decodeGoogleURI(uri string) ([]byte, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidExport, err)
+	}
+
+	if u.Scheme != googleScheme || u.Host != googleHost {
+		return nil, fmt.Errorf("%w: unsupported URI %s://%s", ErrInvalidExport, u.Scheme, u.Host)
+	}
+
+	// Some apps do not escape the '+' of the base64 encoding
+	s := strings.ReplaceAll(u.Query().Get("data"), " ", "+")
+	if s == "" {
+		return nil, fmt.Errorf("%w: missing data", ErrInvalidExport)
+	}
+
+	data, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		if data, err = base64.RawStdEncoding.DecodeString(s); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidExport, err)
+		}
+	}
+
+	return data, nil
+}
+
+func (r *Result) addGoogle(b []byte) error {
+	fields, err := decodeProto(b)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidExport, err)
+	}
+
+	var (
+		d       ykoath.CredentialData
+		name    string
+		alg     uint64
+		typ     uint64
+		counter uint64
+	)
+
+	d.Algorithm = ykoath.HmacSha1
+	d.Digits = 6
+
+	for _, f := range fields {
+		switch f.Num {
+		case googleOTPSecret:
+			d.Secret = f.Bytes
+
+		case googleOTPName:
+			name = string(f.Bytes)
+
+		case googleOTPIssuer:
+			d.ID.Issuer = string(f.Bytes)
+
+		case googleOTPAlgorithm:
+			alg = f.Varint
+
+		case googleOTPDigits:
+			if f.Varint == googleDigitsEight {
+				d.Digits = 8
+			}
+
+		case googleOTPType:
+			typ = f.Varint
+
+		case googleOTPCounter:
+			counter = f.Varint
+		}
+	}
+
+	d.ID.Account = name
+
+	// The name may contain the issuer as a prefix
+	if issuer, account, ok := strings.Cut(name, ":"); ok && (d.ID.Issuer == "" || d.ID.Issuer == issuer) {
+		d.ID.Issuer = issuer
+		d.ID.Account = strings.TrimLeft(account, " ")
+	}
+
+	switch alg {
+	case 0, googleAlgorithmSHA1:
+	case googleAlgorithmSHA256:
+		d.Algorithm = ykoath.HmacSha256
+
+	case googleAlgorithmSHA512:
+		d.Algorithm = ykoath.HmacSha512
+
+	case googleAlgorithmMD5:
+		r.skip(name, fmt.Errorf("%w: algorithm MD5", ErrUnsupported))
+		return nil
+
+	default:
+		r.skip(name, fmt.Errorf("%w: algorithm %d", ErrUnsupported, alg))
+		return nil
+	}
+
+	switch typ {
+	case googleTypeHOTP:
+		if err := setCounter(&d, counter); err != nil {
+			r.skip(name, err)
+			return nil
+		}
+
+	case googleTypeTOTP:
+		d.Type = ykoath.Totp
+		d.ID.Period = ykoath.DefaultTimeStep
+
+	default:
+		r.skip(name, fmt.Errorf("%w: type %d", ErrUnsupported, typ))
+		return nil
+	}
+
+	r.add(name, d)
+
+	return nil
+}
+
+func encodeGoogleOTPParameters(d *ykoath.CredentialData) ([]byte, error) {
+	var b []byte
+
+	b = appendBytesField(b, googleOTPSecret, d.Secret)
+	b = appendBytesField(b, googleOTPName, []byte(d.ID.Account))
+
+	if d.ID.Issuer != "" {
+		b = appendBytesField(b, googleOTPIssuer, []byte(d.ID.Issuer))
+	}
+
+	switch d.Algorithm {
+	case ykoath.HmacSha1:
+		b = appendVarintField(b, googleOTPAlgorithm, googleAlgorithmSHA1)
+
+	case ykoath.HmacSha256:
+		b = appendVarintField(b, googleOTPAlgorithm, googleAlgorithmSHA256)
+
+	case ykoath.HmacSha512:
+		b = appendVarintField(b, googleOTPAlgorithm, googleAlgorithmSHA512)
+
+	default:
+		return nil, fmt.Errorf("%w: algorithm %s", ErrUnsupported, d.Algorithm)
+	}
+
+	switch d.Digits {
+	case 6:
+		b = appendVarintField(b, googleOTPDigits, googleDigitsSix)
+
+	case 8:
+		b = appendVarintField(b, googleOTPDigits, googleDigitsEight)
+
+	default:
+		return nil, fmt.Errorf("%w: %d digits", ErrUnsupported, d.Digits)
+	}
+
+	switch d.Type {
+	case ykoath.Hotp:
+		b = appendVarintField(b, googleOTPType, googleTypeHOTP)
+		b = appendVarintField(b, googleOTPCounter, uint64(d.Counter))
+
+	case ykoath.Totp:
+		if d.ID.Period != 0 && d.ID.Period != ykoath.DefaultTimeStep {
+			return nil, fmt.Errorf("%w: period %s", ErrUnsupported, d.ID.Period)
+		}
+
+		b = appendVarintField(b, googleOTPType, googleTypeTOTP)
+
+	default:
+		return nil, fmt.Errorf("%w: type %s", ErrUnsupported, d.Type)
+	}
+
+	if len(d.Secret) == 0 {
+		return nil, ykoath.ErrMissingSecret
+	}
+
+	return b, nil
+}
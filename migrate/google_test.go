@@ -0,0 +1,127 @@
+// SPDX-FileCopyrightText: 2023 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package migrate_test
+
+import (
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/binary"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	ykoath "cunicu.li/go-ykoath/v2"
+	"cunicu.li/go-ykoath/v2/migrate"
+)
+
+func TestDecodeGoogleAuthenticator(t *testing.T) {
+	require := require.New(t)
+
+	r, err := migrate.DecodeGoogleAuthenticator("otpauth-migration://offline?data=CjEKCkhlbGxvId6tvu8SGEV4YW1wbGU6YWxpY2VAZ29vZ2xlLmNvbRoHRXhhbXBsZTAC")
+	require.NoError(err)
+	require.Empty(r.Skipped)
+	require.Equal([]ykoath.CredentialData{
+		{
+			ID: ykoath.CredentialID{
+				Period:  ykoath.DefaultTimeStep,
+				Issuer:  "Example",
+				Account: "alice@google.com",
+			},
+			Algorithm: ykoath.HmacSha1,
+			Type:      ykoath.Totp,
+			Digits:    6,
+			Secret:    []byte("Hello!\xde\xad\xbe\xef"),
+		},
+	}, r.Credentials)
+}
+
+func TestGoogleAuthenticatorRoundTrip(t *testing.T) {
+	require := require.New(t)
+
+	var creds []ykoath.CredentialData
+	for i := range 25 {
+		d := ykoath.CredentialData{
+			ID: ykoath.CredentialID{
+				Period:  ykoath.DefaultTimeStep,
+				Issuer:  "Example",
+				Account: strings.Repeat("a", i+1),
+			},
+			Algorithm: ykoath.HmacSha256,
+			Type:      ykoath.Totp,
+			Digits:    8,
+			Secret:    []byte("12345678901234567890"),
+		}
+
+		if i%5 == 0 {
+			d.ID.Period = 0
+			d.Type = ykoath.Hotp
+			d.Algorithm = ykoath.HmacSha512
+			d.Digits = 6
+			d.Counter = uint32(i) //nolint:gosec
+		}
+
+		creds = append(creds, d)
+	}
+
+	uris, err := migrate.EncodeGoogleAuthenticator(creds, migrate.GoogleBatchSize)
+	require.NoError(err)
+	require.Len(uris, 3)
+
+	r, err := migrate.DecodeGoogleAuthenticator(uris...)
+	require.NoError(err)
+	require.Empty(r.Skipped)
+	require.Equal(creds, r.Credentials)
+
+	_, err = migrate.DecodeGoogleAuthenticator(uris[0], uris[2])
+	require.ErrorIs(err, migrate.ErrIncompleteBatch)
+
+	_, err = migrate.DecodeGoogleAuthenticator(uris[0], uris[0])
+	require.ErrorIs(err, migrate.ErrInvalidExport)
+
+	_, err = migrate.DecodeGoogleAuthenticator("otpauth://totp/test?secret=JBSWY3DPEHPK3PXP")
+	require.ErrorIs(err, migrate.ErrInvalidExport)
+
+	// Batch parameters from the payload are not trusted
+	for _, batch := range [][2]uint64{
+		{1 << 62, 0}, // size
+		{1 << 63, 0}, // overflowing size
+		{0, 0},       // size
+		{1, 1},       // index
+		{1, 1 << 63}, // overflowing index
+	} {
+		_, err = migrate.DecodeGoogleAuthenticator(googleBatchURI(batch[0], batch[1]))
+		require.Error(err)
+	}
+
+	_, err = migrate.DecodeGoogleAuthenticator(googleBatchURI(1<<62, 0))
+	require.ErrorIs(err, migrate.ErrIncompleteBatch)
+
+	// Google Authenticator only supports the default period
+	creds[1].ID.Period = 60 * time.Second
+
+	_, err = migrate.EncodeGoogleAuthenticator(creds, 0)
+	require.ErrorIs(err, migrate.ErrUnsupported)
+}
+
+func fromBase32(s string) []byte {
+	b, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+
+	return b
+}
+
+// googleBatchURI returns the URI of an empty batch of an export
+func googleBatchURI(size, index uint64) string {
+	var data []byte
+
+	data = binary.AppendUvarint(append(data, 3<<3), size)
+	data = binary.AppendUvarint(append(data, 4<<3), index)
+
+	return "otpauth-migration://offline?data=" + url.QueryEscape(base64.StdEncoding.EncodeToString(data))
+}
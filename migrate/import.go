@@ -0,0 +1,142 @@
+// SPDX-FileCopyrightText: 2023 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	ykoath "cunicu.li/go-ykoath/v2"
+)
+
+var ErrDuplicateName = errors.New("duplicate name")
+
+// Written is a credential which is written to the card by Import.
+type Written struct {
+	// Name is the name of the credential on the card.
+	Name string
+
+	// Original is the name of the credential in the export.
+	// It differs from Name if the name has been truncated.
+	Original string
+
+	// Overwrite is true if the card already holds a credential with the same name.
+	Overwrite bool
+}
+
+// Truncated returns true if the name has been shortened to fit on the card.
+func (w *Written) Truncated() bool {
+	return w.Name != w.Original
+}
+
+// Report summarizes the changes made by Import.
+type Report struct {
+	DryRun  bool
+	Written []Written
+	Skipped []Skipped
+}
+
+// String returns a human readable summary of the report
+func (r *Report) String() string {
+	var sb strings.Builder
+
+	verb := "Wrote"
+	if r.DryRun {
+		verb = "Would write"
+	}
+
+	fmt.Fprintf(&sb, "%s %d credentials:\n", verb, len(r.Written))
+
+	for _, w := range r.Written {
+		fmt.Fprintf(&sb, "  %s", w.Name)
+
+		if w.Truncated() {
+			fmt.Fprintf(&sb, " (truncated from %s)", w.Original)
+		}
+
+		if w.Overwrite {
+			sb.WriteString(" (overwrite)")
+		}
+
+		sb.WriteByte('\n')
+	}
+
+	if len(r.Skipped) > 0 {
+		fmt.Fprintf(&sb, "Skipped %d entries:\n", len(r.Skipped))
+
+		for _, s := range r.Skipped {
+			fmt.Fprintf(&sb, "  %s\n", s.String())
+		}
+	}
+
+	return sb.String()
+}
+
+// Import writes all credentials of the result to the card.
+// If dryRun is true, the card is left untouched and the report only
+// describes what would be written.
+// Credentials whose names collide with an earlier entry of the result,
+// e.g. due to truncation, are skipped.
+func Import(ctx context.Context, card *ykoath.Card, r *Result, dryRun bool) (*Report, error) {
+	names, err := card.ListContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list credentials: %w", err)
+	}
+
+	existing := map[string]bool{}
+	for _, n := range names {
+		existing[n.Name] = true
+	}
+
+	// Several entries might have been truncated to the same name
+	original := map[string][]string{}
+	for _, t := range r.Truncated {
+		original[t.Name] = append(original[t.Name], t.Original)
+	}
+
+	rep := &Report{
+		DryRun:  dryRun,
+		Skipped: append([]Skipped{}, r.Skipped...),
+	}
+
+	written := map[string]bool{}
+
+	for i := range r.Credentials {
+		d := &r.Credentials[i]
+		name := d.ID.String()
+
+		w := Written{
+			Name:      name,
+			Original:  name,
+			Overwrite: existing[name],
+		}
+
+		if o := original[name]; len(o) > 0 {
+			w.Original = o[0]
+			original[name] = o[1:]
+		}
+
+		if written[name] {
+			rep.Skipped = append(rep.Skipped, Skipped{
+				Name:   w.Original,
+				Reason: fmt.Errorf("%w: %s", ErrDuplicateName, name),
+			})
+
+			continue
+		}
+
+		if !dryRun {
+			if err := card.PutCredentialContext(ctx, d); err != nil {
+				return rep, fmt.Errorf("failed to put %s: %w", name, err)
+			}
+		}
+
+		written[name] = true
+		rep.Written = append(rep.Written, w)
+	}
+
+	return rep, nil
+}
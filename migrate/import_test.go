@@ -0,0 +1,82 @@
+// SPDX-FileCopyrightText: 2023 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package migrate_test
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	ykoath "cunicu.li/go-ykoath/v2"
+	"cunicu.li/go-ykoath/v2/emulator"
+	"cunicu.li/go-ykoath/v2/migrate"
+)
+
+func TestImport(t *testing.T) {
+	require := require.New(t)
+
+	b, err := os.ReadFile("testdata/2fas-plain.2fas")
+	require.NoError(err)
+
+	r, err := migrate.DecodeTwoFAS(b, nil)
+	require.NoError(err)
+
+	card, err := ykoath.NewCard(emulator.NewCard())
+	require.NoError(err)
+
+	defer card.Close()
+
+	_, err = card.Select()
+	require.NoError(err)
+
+	err = card.Put(ykoath.CredentialID{Issuer: "Example", Account: "alice@example.com"}, ykoath.HmacSha1, ykoath.Totp, 6, []byte("secret"), false, 0)
+	require.NoError(err)
+
+	// A dry-run leaves the card untouched
+	rep, err := migrate.Import(context.Background(), card, r, true)
+	require.NoError(err)
+	require.True(rep.DryRun)
+	require.Len(rep.Written, 4)
+	require.Len(rep.Skipped, 1)
+
+	require.True(rep.Written[0].Overwrite)
+	require.False(rep.Written[1].Overwrite)
+
+	require.True(rep.Written[3].Truncated())
+	require.Equal(r.Truncated[0].Original, rep.Written[3].Original)
+
+	require.Equal(`Would write 4 credentials:
+  Example:alice@example.com (overwrite)
+  60/SPDX:James
+  Counter:bob
+  A service with an exceptionally long name:somebody.with.a.long.a (truncated from A service with an exceptionally long name:somebody.with.a.long.address@example.com)
+Skipped 1 entries:
+  Mobile:carol: unsupported credential: algorithm MD5
+`, rep.String())
+
+	names, err := card.List()
+	require.NoError(err)
+	require.Len(names, 1)
+
+	// Now write the credentials
+	rep, err = migrate.Import(context.Background(), card, r, false)
+	require.NoError(err)
+	require.False(rep.DryRun)
+	require.Len(rep.Written, 4)
+
+	names, err = card.List()
+	require.NoError(err)
+	require.Len(names, 4)
+
+	// Entries which collide after truncation are skipped
+	r.Credentials = append(r.Credentials, r.Credentials[3])
+
+	rep, err = migrate.Import(context.Background(), card, r, true)
+	require.NoError(err)
+	require.Len(rep.Written, 4)
+	require.Len(rep.Skipped, 2)
+	require.ErrorIs(rep.Skipped[1].Reason, migrate.ErrDuplicateName)
+}
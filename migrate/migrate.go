@@ -0,0 +1,97 @@
+// SPDX-FileCopyrightText: 2023 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+// Package migrate converts OATH credentials from and to the
+// export formats of other authenticator apps.
+package migrate
+
+import (
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"math"
+
+	ykoath "cunicu.li/go-ykoath/v2"
+)
+
+var (
+	ErrUnsupported   = errors.New("unsupported credential")
+	ErrInvalidExport = errors.New("invalid export")
+)
+
+// Skipped is an entry of an export which could not be converted into a credential.
+type Skipped struct {
+	Name   string
+	Reason error
+}
+
+func (s *Skipped) String() string {
+	return fmt.Sprintf("%s: %s", s.Name, s.Reason)
+}
+
+// Truncated is a credential whose name has been shortened to fit into
+// ykoath.MaxNameLength bytes.
+type Truncated struct {
+	Name     string
+	Original string
+}
+
+// Result contains the credentials which have been imported from an export.
+type Result struct {
+	Credentials []ykoath.CredentialData
+	Skipped     []Skipped
+	Truncated   []Truncated
+}
+
+// add validates the credential before adding it to the result.
+// Names which exceed the length supported by the card are truncated.
+func (r *Result) add(name string, d ykoath.CredentialData) {
+	if id, ok := d.ID.Truncate(); ok {
+		r.Truncated = append(r.Truncated, Truncated{
+			Name:     id.String(),
+			Original: d.ID.String(),
+		})
+
+		d.ID = id
+	}
+
+	if err := d.Validate(); err != nil {
+		r.skip(name, err)
+		return
+	}
+
+	r.Credentials = append(r.Credentials, d)
+}
+
+func (r *Result) skip(name string, err error) {
+	r.Skipped = append(r.Skipped, Skipped{
+		Name:   name,
+		Reason: err,
+	})
+}
+
+// setCounter turns d into an HOTP credential with the given counter.
+// Counters which exceed the 32 bits supported by the card are rejected.
+func setCounter(d *ykoath.CredentialData, counter uint64) error {
+	if counter > math.MaxUint32 {
+		return fmt.Errorf("%w: counter %d", ErrUnsupported, counter)
+	}
+
+	d.Type = ykoath.Hotp
+	d.Counter = uint32(counter)
+
+	return nil
+}
+
+// completeID uses the issuer as account of entries which are only identified
+// by a single name like the name of a service or the title of an entry.
+func completeID(id *ykoath.CredentialID) {
+	if id.Account == "" {
+		id.Account = id.Issuer
+		id.Issuer = ""
+	}
+}
+
+func encodeBase32(b []byte) string {
+	return base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(b)
+}
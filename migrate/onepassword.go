@@ -0,0 +1,110 @@
+// SPDX-FileCopyrightText: 2023 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package migrate
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// 1Password exports its vaults as a ZIP archive (.1pux) which contains
+// the items in the JSON document "export.data". One-time passwords are
+// stored as otpauth:// URIs in fields of the item sections.
+// See: https://support.1password.com/1pux-format/
+
+const (
+	onePasswordDataFile            = "export.data"
+	onePasswordDesignationUsername = "username"
+	onePasswordStateTrashed        = "trashed"
+)
+
+type onePasswordField struct {
+	Title string `json:"title"`
+	Value struct {
+		TOTP string `json:"totp"`
+	} `json:"value"`
+}
+
+//nolint:tagliatelle
+type onePasswordItem struct {
+	State    string `json:"state"`
+	Overview struct {
+		Title string `json:"title"`
+	} `json:"overview"`
+	Details struct {
+		LoginFields []struct {
+			Designation string `json:"designation"`
+			Value       string `json:"value"`
+		} `json:"loginFields"`
+		Sections []struct {
+			Fields []onePasswordField `json:"fields"`
+		} `json:"sections"`
+	} `json:"details"`
+}
+
+type onePasswordExport struct {
+	Accounts []struct {
+		Vaults []struct {
+			Items []onePasswordItem `json:"items"`
+		} `json:"vaults"`
+	} `json:"accounts"`
+}
+
+// DecodeOnePasswordPUX decodes the TOTP credentials of a 1Password export (.1pux).
+// Items without a one-time password and trashed items are ignored.
+// Items with several one-time passwords yield a credential for each of them.
+func DecodeOnePasswordPUX(b []byte) (*Result, error) {
+	zr, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidExport, err)
+	}
+
+	f, err := zr.Open(onePasswordDataFile)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidExport, err)
+	}
+
+	defer f.Close()
+
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidExport, err)
+	}
+
+	var export onePasswordExport
+	if err := json.Unmarshal(data, &export); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidExport, err)
+	}
+
+	r := &Result{}
+	for _, a := range export.Accounts {
+		for _, v := range a.Vaults {
+			for _, i := range v.Items {
+				if i.State != onePasswordStateTrashed {
+					r.addOnePassword(&i)
+				}
+			}
+		}
+	}
+
+	return r, nil
+}
+
+func (r *Result) addOnePassword(i *onePasswordItem) {
+	var username string
+	for _, f := range i.Details.LoginFields {
+		if f.Designation == onePasswordDesignationUsername {
+			username = f.Value
+		}
+	}
+
+	for _, s := range i.Details.Sections {
+		for _, f := range s.Fields {
+			r.addOTP(i.Overview.Title, username, f.Value.TOTP)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+// SPDX-FileCopyrightText: 2023 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package migrate_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	ykoath "cunicu.li/go-ykoath/v2"
+	"cunicu.li/go-ykoath/v2/migrate"
+)
+
+func TestDecodeOnePasswordPUX(t *testing.T) {
+	require := require.New(t)
+
+	data, err := os.ReadFile("testdata/1password-export.data")
+	require.NoError(err)
+
+	var buf bytes.Buffer
+
+	zw := zip.NewWriter(&buf)
+
+	w, err := zw.Create("export.attributes")
+	require.NoError(err)
+
+	_, err = w.Write([]byte(`{"version": 3, "description": "1Password Unencrypted Export"}`))
+	require.NoError(err)
+
+	w, err = zw.Create("export.data")
+	require.NoError(err)
+
+	_, err = w.Write(data)
+	require.NoError(err)
+
+	err = zw.Close()
+	require.NoError(err)
+
+	r, err := migrate.DecodeOnePasswordPUX(buf.Bytes())
+	require.NoError(err)
+	require.Empty(r.Skipped)
+	require.Len(r.Credentials, 2)
+
+	require.Equal(ykoath.CredentialID{
+		Period:  ykoath.DefaultTimeStep,
+		Issuer:  "Example",
+		Account: "alice@example.com",
+	}, r.Credentials[0].ID)
+	require.Equal([]byte("12345678901234567890"), r.Credentials[0].Secret)
+
+	require.Equal(ykoath.CredentialID{
+		Period:  ykoath.DefaultTimeStep,
+		Issuer:  "SPDX",
+		Account: "James",
+	}, r.Credentials[1].ID)
+
+	_, err = migrate.DecodeOnePasswordPUX(data)
+	require.ErrorIs(err, migrate.ErrInvalidExport)
+}
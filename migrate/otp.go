@@ -0,0 +1,148 @@
+// SPDX-FileCopyrightText: 2023 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package migrate
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	ykoath "cunicu.li/go-ykoath/v2"
+)
+
+// Password managers store TOTP seeds alongside the logins either as
+// otpauth:// URIs or as bare Base32 secrets. Bitwarden additionally uses
+// "steam://" followed by the secret for Steam Guard codes.
+
+const steamScheme = "steam://"
+
+// addOTP adds the one-time password of a password manager entry.
+// The title and username of the entry are used as issuer and account
+// if the value does not provide them.
+// Entries without a value are ignored.
+func (r *Result) addOTP(title, username, value string) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return
+	}
+
+	name := title
+	if username != "" && title != "" {
+		name = title + ":" + username
+	} else if username != "" {
+		name = username
+	}
+
+	var (
+		d   *ykoath.CredentialData
+		err error
+	)
+
+	switch {
+	case strings.HasPrefix(strings.ToLower(value), "otpauth://"):
+		if d, err = parseURI(value); err != nil {
+			r.skip(name, err)
+			return
+		}
+
+		if d.ID.Account == "" {
+			d.ID.Account = username
+		}
+
+		if d.ID.Issuer == "" && d.ID.Account != title {
+			d.ID.Issuer = title
+		}
+
+	case strings.HasPrefix(strings.ToLower(value), steamScheme):
+		account := username
+		if account == "" {
+			account = title
+		}
+
+		d = &ykoath.CredentialData{
+			ID: ykoath.CredentialID{
+				Period:  ykoath.DefaultTimeStep,
+				Issuer:  ykoath.SteamIssuer,
+				Account: account,
+			},
+			Algorithm: ykoath.HmacSha1,
+			Type:      ykoath.Totp,
+			Digits:    ykoath.MinDigits,
+		}
+
+		if d.Secret, err = ykoath.DecodeSecret(value[len(steamScheme):]); err != nil {
+			r.skip(name, fmt.Errorf("%w: %w", ykoath.ErrInvalidSecret, err))
+			return
+		}
+
+	default:
+		d = &ykoath.CredentialData{
+			ID: ykoath.CredentialID{
+				Period:  ykoath.DefaultTimeStep,
+				Issuer:  title,
+				Account: username,
+			},
+			Algorithm: ykoath.HmacSha1,
+			Type:      ykoath.Totp,
+			Digits:    ykoath.MinDigits,
+		}
+
+		if d.Secret, err = ykoath.DecodeSecret(value); err != nil {
+			r.skip(name, fmt.Errorf("%w: %w", ykoath.ErrInvalidSecret, err))
+			return
+		}
+	}
+
+	// Entries without a username are only identified by their title
+	completeID(&d.ID)
+
+	r.add(name, *d)
+}
+
+// parseURI parses an otpauth:// URI.
+// In contrast to ykoath.ParseURI, empty labels and labels which are
+// too long for the card are accepted so that they can be completed
+// or truncated later.
+func parseURI(uri string) (*ykoath.CredentialData, error) {
+	d, err := ykoath.ParseURI(uri)
+	if !errors.Is(err, ykoath.ErrNameTooLong) && !errors.Is(err, ykoath.ErrNameTooShort) {
+		return d, err
+	}
+
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ykoath.ErrInvalidURI, err)
+	}
+
+	var id ykoath.CredentialID
+
+	label := strings.TrimPrefix(u.Path, "/")
+	if issuer, account, ok := strings.Cut(label, ":"); ok {
+		id.Issuer = issuer
+		id.Account = strings.TrimLeft(account, " ")
+	} else {
+		id.Account = label
+	}
+
+	q := u.Query()
+	if id.Issuer == "" {
+		id.Issuer = q.Get("issuer")
+	}
+
+	// Parse the remaining parameters with a placeholder label
+	q.Del("issuer")
+	u.Path = "/-"
+	u.RawPath = ""
+	u.RawQuery = q.Encode()
+
+	if d, err = ykoath.ParseURI(u.String()); err != nil {
+		return nil, err
+	}
+
+	d.ID.Issuer = id.Issuer
+	d.ID.Account = id.Account
+
+	return d, nil
+}
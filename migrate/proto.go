@@ -0,0 +1,99 @@
+// SPDX-FileCopyrightText: 2023 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package migrate
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// Minimal protocol buffer wire format support
+// See: https://protobuf.dev/programming-guides/encoding/
+
+type wireType byte
+
+const (
+	wireVarint wireType = 0
+	wireI64    wireType = 1
+	wireLen    wireType = 2
+	wireI32    wireType = 5
+)
+
+var (
+	errTruncated       = errors.New("truncated message")
+	errInvalidWireType = errors.New("invalid wire type")
+)
+
+type protoField struct {
+	Num    int
+	Type   wireType
+	Varint uint64
+	Bytes  []byte
+}
+
+func decodeProto(b []byte) (fields []protoField, err error) {
+	for len(b) > 0 {
+		key, n := binary.Uvarint(b)
+		if n <= 0 {
+			return nil, errTruncated
+		}
+
+		b = b[n:]
+
+		f := protoField{
+			Num:  int(key >> 3), //nolint:gosec
+			Type: wireType(key & 0x7),
+		}
+
+		switch f.Type {
+		case wireVarint:
+			if f.Varint, n = binary.Uvarint(b); n <= 0 {
+				return nil, errTruncated
+			}
+
+			b = b[n:]
+
+		case wireLen:
+			l, n := binary.Uvarint(b)
+			if n <= 0 || uint64(len(b)-n) < l {
+				return nil, errTruncated
+			}
+
+			f.Bytes = b[n : n+int(l)] //nolint:gosec
+			b = b[n+int(l):]          //nolint:gosec
+
+		case wireI64, wireI32:
+			l := 8
+			if f.Type == wireI32 {
+				l = 4
+			}
+
+			if len(b) < l {
+				return nil, errTruncated
+			}
+
+			f.Bytes = b[:l]
+			b = b[l:]
+
+		default:
+			return nil, fmt.Errorf("%w: %d", errInvalidWireType, f.Type)
+		}
+
+		fields = append(fields, f)
+	}
+
+	return fields, nil
+}
+
+func appendVarintField(b []byte, num int, v uint64) []byte {
+	b = binary.AppendUvarint(b, uint64(num)<<3|uint64(wireVarint)) //nolint:gosec
+	return binary.AppendUvarint(b, v)
+}
+
+func appendBytesField(b []byte, num int, v []byte) []byte {
+	b = binary.AppendUvarint(b, uint64(num)<<3|uint64(wireLen)) //nolint:gosec
+	b = binary.AppendUvarint(b, uint64(len(v)))
+	return append(b, v...)
+}
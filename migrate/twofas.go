@@ -0,0 +1,193 @@
+// SPDX-FileCopyrightText: 2023 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package migrate
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/pbkdf2"
+
+	ykoath "cunicu.li/go-ykoath/v2"
+)
+
+// 2FAS exports its services as a JSON document (.2fas).
+// Password protected backups contain the services in the field "servicesEncrypted"
+// as "ciphertext:salt:iv" encoded in Base64. The key for AES-256-GCM is derived
+// from the password with PBKDF2-HMAC-SHA256.
+// See: https://github.com/twofas/2fas-android
+
+const (
+	twoFASIterations = 10000
+	twoFASKeySize    = 32
+)
+
+//nolint:tagliatelle
+type twoFASOTP struct {
+	Label     string `json:"label"`
+	Account   string `json:"account"`
+	Issuer    string `json:"issuer"`
+	Digits    int    `json:"digits"`
+	Period    int    `json:"period"`
+	Algorithm string `json:"algorithm"`
+	Counter   uint64 `json:"counter"`
+	TokenType string `json:"tokenType"`
+}
+
+type twoFASService struct {
+	Name   string    `json:"name"`
+	Secret string    `json:"secret"`
+	OTP    twoFASOTP `json:"otp"`
+}
+
+//nolint:tagliatelle
+type twoFASBackup struct {
+	Services          []twoFASService `json:"services"`
+	ServicesEncrypted string          `json:"servicesEncrypted"`
+	SchemaVersion     int             `json:"schemaVersion"`
+}
+
+// DecodeTwoFAS decodes a plain or password protected 2FAS backup.
+// The password is only required for protected backups.
+func DecodeTwoFAS(b []byte, password []byte) (*Result, error) {
+	var backup twoFASBackup
+	if err := json.Unmarshal(b, &backup); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidExport, err)
+	}
+
+	services := backup.Services
+
+	if backup.ServicesEncrypted != "" {
+		if password == nil {
+			return nil, ErrPasswordRequired
+		}
+
+		data, err := twoFASOpen(backup.ServicesEncrypted, password)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := json.Unmarshal(data, &services); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidExport, err)
+		}
+	}
+
+	r := &Result{}
+	for _, s := range services {
+		r.addTwoFAS(&s)
+	}
+
+	return r, nil
+}
+
+func twoFASOpen(enc string, password []byte) ([]byte, error) {
+	parts := strings.Split(enc, ":")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("%w: malformed encrypted services", ErrInvalidExport)
+	}
+
+	var fields [3][]byte
+	for i, p := range parts {
+		var err error
+		if fields[i], err = base64.StdEncoding.DecodeString(p); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidExport, err)
+		}
+	}
+
+	ct, salt, iv := fields[0], fields[1], fields[2]
+
+	key := pbkdf2.Key(password, salt, twoFASIterations, twoFASKeySize, sha256.New)
+
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(iv) != aead.NonceSize() {
+		return nil, fmt.Errorf("%w: invalid nonce size", ErrInvalidExport)
+	}
+
+	data, err := aead.Open(nil, iv, ct, nil)
+	if err != nil {
+		return nil, ErrWrongPassword
+	}
+
+	return data, nil
+}
+
+func (r *Result) addTwoFAS(s *twoFASService) {
+	d := ykoath.CredentialData{
+		ID: ykoath.CredentialID{
+			Issuer:  s.OTP.Issuer,
+			Account: s.OTP.Account,
+		},
+		Digits: s.OTP.Digits,
+	}
+
+	// The service name is the issuer if none has been given explicitly
+	if d.ID.Issuer == "" {
+		d.ID.Issuer = s.Name
+	}
+
+	if d.ID.Account == "" {
+		d.ID.Account = s.OTP.Label
+	}
+
+	// Entries without an account only have a service name
+	completeID(&d.ID)
+
+	if d.Digits == 0 {
+		d.Digits = ykoath.MinDigits
+	}
+
+	name := d.ID.String()
+
+	alg, err := ykoath.ParseAlgorithm(s.OTP.Algorithm)
+	if err != nil {
+		r.skip(name, fmt.Errorf("%w: algorithm %s", ErrUnsupported, s.OTP.Algorithm))
+		return
+	}
+
+	d.Algorithm = alg
+
+	switch strings.ToUpper(s.OTP.TokenType) {
+	case "TOTP", "":
+		d.Type = ykoath.Totp
+		d.ID.Period = time.Duration(s.OTP.Period) * time.Second
+
+	case "HOTP":
+		if err := setCounter(&d, s.OTP.Counter); err != nil {
+			r.skip(name, err)
+			return
+		}
+
+	case "STEAM":
+		d.Type = ykoath.Totp
+		d.ID.Period = time.Duration(s.OTP.Period) * time.Second
+		d.ID.Issuer = ykoath.SteamIssuer
+		d.Digits = ykoath.MinDigits
+
+	default:
+		r.skip(name, fmt.Errorf("%w: type %s", ErrUnsupported, s.OTP.TokenType))
+		return
+	}
+
+	if d.Type == ykoath.Totp && d.ID.Period == 0 {
+		d.ID.Period = ykoath.DefaultTimeStep
+	}
+
+	secret, err := ykoath.DecodeSecret(s.Secret)
+	if err != nil {
+		r.skip(name, fmt.Errorf("%w: %w", ykoath.ErrInvalidSecret, err))
+		return
+	}
+
+	d.Secret = secret
+
+	r.add(name, d)
+}
@@ -0,0 +1,160 @@
+// SPDX-FileCopyrightText: 2023 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package migrate_test
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/pbkdf2"
+
+	ykoath "cunicu.li/go-ykoath/v2"
+	"cunicu.li/go-ykoath/v2/migrate"
+)
+
+func TestDecodeTwoFAS(t *testing.T) {
+	require := require.New(t)
+
+	b, err := os.ReadFile("testdata/2fas-plain.2fas")
+	require.NoError(err)
+
+	r, err := migrate.DecodeTwoFAS(b, nil)
+	require.NoError(err)
+	require.Len(r.Credentials, 4)
+
+	// The service name is used as issuer
+	require.Equal(ykoath.CredentialData{
+		ID: ykoath.CredentialID{
+			Period:  60 * time.Second,
+			Issuer:  "SPDX",
+			Account: "James",
+		},
+		Algorithm: ykoath.HmacSha256,
+		Type:      ykoath.Totp,
+		Digits:    8,
+		Secret:    fromBase32("5OM4WOOGPLQEF6UGN3CPEOOLWU"),
+	}, r.Credentials[1])
+
+	require.Equal(ykoath.Hotp, r.Credentials[2].Type)
+	require.Equal(ykoath.HmacSha512, r.Credentials[2].Algorithm)
+	require.EqualValues(42, r.Credentials[2].Counter)
+
+	// Long names are truncated to fit on the card
+	require.Equal(ykoath.CredentialID{
+		Period:  ykoath.DefaultTimeStep,
+		Issuer:  "A service with an exceptionally long name",
+		Account: "somebody.with.a.long.a",
+	}, r.Credentials[3].ID)
+
+	require.Equal([]migrate.Truncated{
+		{
+			Name:     "A service with an exceptionally long name:somebody.with.a.long.a",
+			Original: "A service with an exceptionally long name:somebody.with.a.long.address@example.com",
+		},
+	}, r.Truncated)
+
+	require.Len(r.Skipped, 1)
+	require.Equal("Mobile:carol", r.Skipped[0].Name)
+	require.ErrorIs(r.Skipped[0].Reason, migrate.ErrUnsupported)
+}
+
+func TestDecodeTwoFASCompleteID(t *testing.T) {
+	require := require.New(t)
+
+	b := []byte(`{
+		"schemaVersion": 4,
+		"services": [
+			{
+				"name": "Example",
+				"secret": "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ",
+				"otp": { "tokenType": "TOTP" }
+			},
+			{
+				"name": "Overflow",
+				"secret": "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ",
+				"otp": { "account": "bob", "tokenType": "HOTP", "counter": 4294967296 }
+			}
+		]
+	}`)
+
+	r, err := migrate.DecodeTwoFAS(b, nil)
+	require.NoError(err)
+
+	// Services without an account are only identified by their name
+	require.Len(r.Credentials, 1)
+	require.Equal(ykoath.CredentialID{
+		Period:  ykoath.DefaultTimeStep,
+		Account: "Example",
+	}, r.Credentials[0].ID)
+
+	// Counters exceeding 32 bits are not supported by the card
+	require.Len(r.Skipped, 1)
+	require.Equal("Overflow:bob", r.Skipped[0].Name)
+	require.ErrorIs(r.Skipped[0].Reason, migrate.ErrUnsupported)
+}
+
+func TestDecodeTwoFASEncrypted(t *testing.T) {
+	require := require.New(t)
+
+	plain, err := os.ReadFile("testdata/2fas-plain.2fas")
+	require.NoError(err)
+
+	expected, err := migrate.DecodeTwoFAS(plain, nil)
+	require.NoError(err)
+
+	password := []byte("test")
+
+	b := encryptTwoFAS(t, password, plain)
+
+	_, err = migrate.DecodeTwoFAS(b, nil)
+	require.ErrorIs(err, migrate.ErrPasswordRequired)
+
+	_, err = migrate.DecodeTwoFAS(b, []byte("wrong"))
+	require.ErrorIs(err, migrate.ErrWrongPassword)
+
+	r, err := migrate.DecodeTwoFAS(b, password)
+	require.NoError(err)
+	require.Equal(expected, r)
+}
+
+func encryptTwoFAS(t *testing.T, password, plain []byte) []byte {
+	require := require.New(t)
+
+	var backup map[string]any
+	err := json.Unmarshal(plain, &backup)
+	require.NoError(err)
+
+	services, err := json.Marshal(backup["services"])
+	require.NoError(err)
+
+	salt := make([]byte, 256)
+	iv := make([]byte, 12)
+
+	for _, b := range [][]byte{salt, iv} {
+		_, err := rand.Read(b)
+		require.NoError(err)
+	}
+
+	key := pbkdf2.Key(password, salt, 10000, 32, sha256.New)
+	ct := seal(t, key, iv, services)
+
+	backup["services"] = []any{}
+	backup["servicesEncrypted"] = strings.Join([]string{
+		base64.StdEncoding.EncodeToString(ct),
+		base64.StdEncoding.EncodeToString(salt),
+		base64.StdEncoding.EncodeToString(iv),
+	}, ":")
+
+	b, err := json.Marshal(backup)
+	require.NoError(err)
+
+	return b
+}
@@ -0,0 +1,183 @@
+// SPDX-FileCopyrightText: 2023 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package ykoath
+
+import (
+	"context"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidURI    = errors.New("invalid otpauth URI")
+	ErrInvalidSecret = errors.New("invalid secret")
+	ErrInvalidIssuer = errors.New("invalid issuer")
+)
+
+const uriScheme = "otpauth"
+
+// ParseURI parses an otpauth:// URI in the Key URI format.
+// See: https://github.com/google/google-authenticator/wiki/Key-Uri-Format
+//
+// Invalid parameters are reported at once as ValidationErrors.
+func ParseURI(uri string) (*CredentialData, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidURI, err)
+	}
+
+	if u.Scheme != uriScheme {
+		return nil, fmt.Errorf("%w: unsupported scheme %q", ErrInvalidURI, u.Scheme)
+	}
+
+	var errs ValidationErrors
+
+	invalid := func(field string, err error) {
+		errs = append(errs, &ValidationError{
+			Field: field,
+			Err:   err,
+		})
+	}
+
+	d := &CredentialData{
+		Algorithm: HmacSha1,
+		Digits:    MinDigits,
+	}
+
+	if d.Type, err = ParseType(u.Host); err != nil {
+		invalid("type", err)
+	} else if d.Type == Totp {
+		d.ID.Period = DefaultTimeStep
+	}
+
+	label := strings.TrimPrefix(u.Path, "/")
+	if issuer, account, ok := strings.Cut(label, ":"); ok {
+		d.ID.Issuer = issuer
+		d.ID.Account = strings.TrimLeft(account, " ")
+	} else {
+		d.ID.Account = label
+	}
+
+	q := u.Query()
+
+	if issuer := q.Get("issuer"); issuer != "" {
+		if d.ID.Issuer == "" {
+			d.ID.Issuer = issuer
+		} else if d.ID.Issuer != issuer {
+			invalid("issuer", fmt.Errorf("%w: label issuer %q does not match parameter %q", ErrInvalidIssuer, d.ID.Issuer, issuer))
+		}
+	}
+
+	if secret := q.Get("secret"); secret != "" {
+		if d.Secret, err = DecodeSecret(secret); err != nil {
+			invalid("secret", fmt.Errorf("%w: %w", ErrInvalidSecret, err))
+		}
+	}
+
+	if alg := q.Get("algorithm"); alg != "" {
+		if d.Algorithm, err = ParseAlgorithm(alg); err != nil {
+			invalid("algorithm", err)
+		}
+	}
+
+	if digits := q.Get("digits"); digits != "" {
+		if d.Digits, err = strconv.Atoi(digits); err != nil {
+			invalid("digits", fmt.Errorf("%w: %q", ErrInvalidDigits, digits))
+		}
+	}
+
+	if period := q.Get("period"); period != "" && d.Type == Totp {
+		if p, err := strconv.Atoi(period); err != nil || p <= 0 {
+			invalid("period", fmt.Errorf("%w: %q", ErrInvalidPeriod, period))
+		} else {
+			d.ID.Period = time.Duration(p) * time.Second
+		}
+	}
+
+	if counter := q.Get("counter"); counter != "" && d.Type == Hotp {
+		if c, err := strconv.ParseUint(counter, 10, 32); err != nil {
+			invalid("counter", fmt.Errorf("%w: %q", ErrInvalidCounter, counter))
+		} else {
+			d.Counter = uint32(c)
+		}
+	}
+
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	if err := d.Validate(); err != nil {
+		return nil, err
+	}
+
+	return d, nil
+}
+
+// URI returns the credential as an otpauth:// URI in the Key URI format.
+func (d *CredentialData) URI() string {
+	label := d.ID.Account
+	if d.ID.Issuer != "" {
+		label = d.ID.Issuer + ":" + label
+	}
+
+	q := url.Values{}
+	q.Set("secret", base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(d.Secret))
+	q.Set("algorithm", strings.TrimPrefix(d.Algorithm.String(), "HMAC-"))
+	q.Set("digits", strconv.Itoa(d.Digits))
+
+	if d.ID.Issuer != "" {
+		q.Set("issuer", d.ID.Issuer)
+	}
+
+	switch d.Type {
+	case Totp:
+		period := d.ID.Period
+		if period == 0 {
+			period = DefaultTimeStep
+		}
+
+		q.Set("period", strconv.Itoa(int(period/time.Second)))
+
+	case Hotp:
+		q.Set("counter", strconv.FormatUint(uint64(d.Counter), 10))
+	}
+
+	u := url.URL{
+		Scheme:   uriScheme,
+		Host:     strings.ToLower(d.Type.String()),
+		Path:     "/" + label,
+		RawQuery: q.Encode(),
+	}
+
+	return u.String()
+}
+
+// PutURI parses an otpauth:// URI and stores the credential on the card.
+func (c *Card) PutURI(uri string) error {
+	return c.PutURIContext(context.Background(), uri)
+}
+
+// PutURIContext is like PutURI but aborts when the context is done
+func (c *Card) PutURIContext(ctx context.Context, uri string) error {
+	d, err := ParseURI(uri)
+	if err != nil {
+		return err
+	}
+
+	return c.PutCredentialContext(ctx, d)
+}
+
+// DecodeSecret decodes a Base32 encoded secret as used by otpauth:// URIs.
+// Padding, whitespace and lower-case letters are tolerated.
+func DecodeSecret(s string) ([]byte, error) {
+	s = strings.ToUpper(strings.Join(strings.Fields(s), ""))
+	s = strings.TrimRight(s, "=")
+
+	return base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(s)
+}
@@ -0,0 +1,178 @@
+// SPDX-FileCopyrightText: 2023 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package ykoath_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	ykoath "cunicu.li/go-ykoath/v2"
+	"cunicu.li/go-ykoath/v2/emulator"
+)
+
+func TestParseURI(t *testing.T) {
+	cases := []struct {
+		URI      string
+		Expected ykoath.CredentialData
+	}{
+		{
+			URI: "otpauth://totp/Example:alice@google.com?secret=JBSWY3DPEHPK3PXP&issuer=Example",
+			Expected: ykoath.CredentialData{
+				ID: ykoath.CredentialID{
+					Period:  ykoath.DefaultTimeStep,
+					Issuer:  "Example",
+					Account: "alice@google.com",
+				},
+				Algorithm: ykoath.HmacSha1,
+				Type:      ykoath.Totp,
+				Digits:    6,
+				Secret:    []byte("Hello!\xde\xad\xbe\xef"),
+			},
+		},
+		{
+			URI: "otpauth://totp/ACME%20Co:%20john.doe@email.com?secret=gezdgnbvgy3tqojqgezdgnbvgy3tqojq&algorithm=SHA256&digits=8&period=60",
+			Expected: ykoath.CredentialData{
+				ID: ykoath.CredentialID{
+					Period:  60 * time.Second,
+					Issuer:  "ACME Co",
+					Account: "john.doe@email.com",
+				},
+				Algorithm: ykoath.HmacSha256,
+				Type:      ykoath.Totp,
+				Digits:    8,
+				Secret:    testSecretSHA1,
+			},
+		},
+		{
+			URI: "otpauth://hotp/test?secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ&counter=42&issuer=Example&algorithm=sha512",
+			Expected: ykoath.CredentialData{
+				ID: ykoath.CredentialID{
+					Issuer:  "Example",
+					Account: "test",
+				},
+				Algorithm: ykoath.HmacSha512,
+				Type:      ykoath.Hotp,
+				Digits:    6,
+				Secret:    testSecretSHA1,
+				Counter:   42,
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.URI, func(t *testing.T) {
+			require := require.New(t)
+
+			d, err := ykoath.ParseURI(tc.URI)
+			require.NoError(err)
+			require.Equal(tc.Expected, *d)
+
+			// Round-trip
+			d2, err := ykoath.ParseURI(d.URI())
+			require.NoError(err)
+			require.Equal(d, d2)
+		})
+	}
+}
+
+func TestParseURIInvalid(t *testing.T) {
+	assert := assert.New(t)
+
+	_, err := ykoath.ParseURI("https://example.com")
+	assert.ErrorIs(err, ykoath.ErrInvalidURI)
+
+	_, err = ykoath.ParseURI("otpauth://motp/test?secret=JBSWY3DPEHPK3PXP")
+	assert.ErrorIs(err, ykoath.ErrInvalidType)
+
+	_, err = ykoath.ParseURI("otpauth://totp/test?secret=JBSWY3DPEHPK3PXP&period=0")
+	assert.ErrorIs(err, ykoath.ErrInvalidPeriod)
+
+	_, err = ykoath.ParseURI("otpauth://totp/Foo:test?secret=JBSWY3DPEHPK3PXP&issuer=Bar")
+	assert.ErrorIs(err, ykoath.ErrInvalidIssuer)
+
+	_, err = ykoath.ParseURI("otpauth://hotp/test?secret=JBSWY3DPEHPK3PXP&counter=-1")
+	assert.ErrorIs(err, ykoath.ErrInvalidCounter)
+
+	_, err = ykoath.ParseURI("otpauth://totp/test?secret=JBSWY3DPEHPK3PX!&algorithm=MD5&digits=10")
+
+	var errs ykoath.ValidationErrors
+	assert.True(errors.As(err, &errs))
+	assert.Len(errs, 2)
+	assert.ErrorIs(err, ykoath.ErrInvalidSecret)
+	assert.ErrorIs(err, ykoath.ErrInvalidAlgorithm)
+
+	// Errors of the credential data are reported after parsing succeeded
+	_, err = ykoath.ParseURI("otpauth://totp/test?digits=10")
+	assert.True(errors.As(err, &errs))
+	assert.Len(errs, 2)
+	assert.ErrorIs(err, ykoath.ErrInvalidDigits)
+	assert.ErrorIs(err, ykoath.ErrMissingSecret)
+
+	_, err = ykoath.ParseURI("otpauth://totp/?secret=JBSWY3DPEHPK3PXP")
+	assert.ErrorIs(err, ykoath.ErrNameTooShort)
+}
+
+func TestPutURI(t *testing.T) {
+	withEmulator(t, emulator.NewCard(), nil, func(t *testing.T, card *ykoath.Card) {
+		require := require.New(t)
+
+		err := card.PutURI("otpauth://totp/Example:test?secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ&digits=8&period=15")
+		require.NoError(err)
+
+		names, err := card.List()
+		require.NoError(err)
+		require.Len(names, 1)
+		require.Equal("15/Example:test", names[0].Name)
+
+		code, err := card.Calculate(names[0].Name)
+		require.NoError(err)
+		require.Equal("26969429", code)
+
+		err = card.PutURI("otpauth://totp/Example:test?digits=8")
+		require.ErrorIs(err, ykoath.ErrMissingSecret)
+	})
+}
+
+func TestParseAlgorithm(t *testing.T) {
+	for s, expected := range map[string]ykoath.Algorithm{
+		"":            ykoath.HmacSha1,
+		"SHA1":        ykoath.HmacSha1,
+		"sha256":      ykoath.HmacSha256,
+		"SHA-512":     ykoath.HmacSha512,
+		"HMAC-SHA256": ykoath.HmacSha256,
+	} {
+		alg, err := ykoath.ParseAlgorithm(s)
+		assert.NoError(t, err, s)
+		assert.Equal(t, expected, alg, s)
+	}
+
+	_, err := ykoath.ParseAlgorithm("MD5")
+	assert.ErrorIs(t, err, ykoath.ErrInvalidAlgorithm)
+}
+
+func TestParseType(t *testing.T) {
+	typ, err := ykoath.ParseType("totp")
+	assert.NoError(t, err)
+	assert.Equal(t, ykoath.Totp, typ)
+
+	typ, err = ykoath.ParseType("HOTP")
+	assert.NoError(t, err)
+	assert.Equal(t, ykoath.Hotp, typ)
+
+	_, err = ykoath.ParseType("")
+	assert.ErrorIs(t, err, ykoath.ErrInvalidType)
+}
+
+func TestDecodeSecret(t *testing.T) {
+	secret, err := ykoath.DecodeSecret("jbsw y3dp ehpk 3pxp====")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("Hello!\xde\xad\xbe\xef"), secret)
+
+	_, err = ykoath.DecodeSecret("not base32!")
+	assert.Error(t, err)
+}
@@ -5,7 +5,9 @@ package ykoath
 
 import (
 	"bytes"
+	"context"
 	"crypto/hmac"
+	"crypto/sha1" //nolint:gosec
 	"errors"
 	"fmt"
 
@@ -15,21 +17,84 @@ import (
 
 var errTokenResponse = errors.New("invalid token response")
 
+// Authenticated returns true if the current session has been authenticated
+// by Validate, ValidateKey, SetCode or SetKey since the applet has been selected.
+func (c *Card) Authenticated() bool {
+	c.acquire(context.Background()) //nolint:errcheck
+	defer c.release()
+
+	return c.authenticated
+}
+
 func (c *Card) RemoveCode() error {
-	_, err := c.send(insSetCode, 0x00, 0x00, tlv.New(tagKey))
+	return c.RemoveCodeContext(context.Background())
+}
+
+// RemoveCodeContext is like RemoveCode but aborts when the context is done
+func (c *Card) RemoveCodeContext(ctx context.Context) error {
+	if err := c.acquire(ctx); err != nil {
+		return err
+	}
+
+	defer c.release()
+
+	_, err := c.send(ctx, insSetCode, 0x00, 0x00, tlv.New(tagKey))
 	return err
 }
 
+// DeriveAccessKey derives the access key from a password and the salt of the applet.
+// Like ykman, PBKDF2-HMAC-SHA1 is used regardless of the algorithm of the access key.
+// Hence, the key is identical to the one derived by ykman.
+func DeriveAccessKey(password, salt []byte) []byte {
+	return pbkdf2.Key(password, salt, 1000, 16, sha1.New)
+}
+
 // SetCode sets a new PIN.
-// This command no authentication.
+// This command requires authentication if a PIN has already been set.
+// The salt of the previously selected applet is used in order to keep
+// the session authenticated.
 func (c *Card) SetCode(code []byte, alg Algorithm) error {
-	sel, err := c.Select()
-	if err != nil {
+	return c.SetCodeContext(context.Background(), code, alg)
+}
+
+// SetCodeContext is like SetCode but aborts when the context is done
+func (c *Card) SetCodeContext(ctx context.Context, code []byte, alg Algorithm) error {
+	if err := c.acquire(ctx); err != nil {
+		return err
+	}
+
+	defer c.release()
+
+	sel := c.selected
+	if sel == nil {
+		var err error
+		if sel, err = c.selectApplet(ctx); err != nil {
+			return err
+		}
+	}
+
+	return c.setKey(ctx, DeriveAccessKey(code, sel.Salt()), alg)
+}
+
+// SetKey sets a new access key which has been derived by DeriveAccessKey.
+// This command requires authentication if an access key has already been set.
+func (c *Card) SetKey(key []byte, alg Algorithm) error {
+	return c.SetKeyContext(context.Background(), key, alg)
+}
+
+// SetKeyContext is like SetKey but aborts when the context is done
+func (c *Card) SetKeyContext(ctx context.Context, key []byte, alg Algorithm) error {
+	if err := c.acquire(ctx); err != nil {
 		return err
 	}
 
-	key := pbkdf2.Key(code, sel.Name, 1000, 16, alg.Hash())
+	defer c.release()
+
+	return c.setKey(ctx, key, alg)
+}
 
+// setKey implements the "SET CODE" instruction
+func (c *Card) setKey(ctx context.Context, key []byte, alg Algorithm) error {
 	myChallenge := make([]byte, 8)
 	if _, err := c.Rand.Read(myChallenge); err != nil {
 		return fmt.Errorf("failed to generate challenge: %w", err)
@@ -41,20 +106,62 @@ func (c *Card) SetCode(code []byte, alg Algorithm) error {
 
 	algKey := append([]byte{byte(alg)}, key...)
 
-	_, err = c.send(insSetCode, 0x00, 0x00,
+	if _, err := c.send(ctx, insSetCode, 0x00, 0x00,
 		tlv.New(tagKey, algKey),
 		tlv.New(tagChallenge, myChallenge),
 		tlv.New(tagResponse, myResponse),
-	)
-	return err
+	); err != nil {
+		return err
+	}
+
+	c.authenticated = true
+
+	return nil
 }
 
-// Reset resets the application to just-installed state.
-// This command requires no authentication.
+// Validate authenticates the session with the PIN.
+// The applet is selected again in order to obtain a fresh challenge.
 func (c *Card) Validate(code []byte) error {
+	return c.ValidateContext(context.Background(), code)
+}
+
+// ValidateContext is like Validate but aborts when the context is done
+func (c *Card) ValidateContext(ctx context.Context, code []byte) error {
+	if err := c.acquire(ctx); err != nil {
+		return err
+	}
+
+	defer c.release()
+
+	return c.validate(ctx, func(sel *Select) []byte {
+		return DeriveAccessKey(code, sel.Salt())
+	})
+}
+
+// ValidateKey authenticates the session with an access key which has been derived by DeriveAccessKey.
+// The applet is selected again in order to obtain a fresh challenge.
+func (c *Card) ValidateKey(key []byte) error {
+	return c.ValidateKeyContext(context.Background(), key)
+}
+
+// ValidateKeyContext is like ValidateKey but aborts when the context is done
+func (c *Card) ValidateKeyContext(ctx context.Context, key []byte) error {
+	if err := c.acquire(ctx); err != nil {
+		return err
+	}
+
+	defer c.release()
+
+	return c.validate(ctx, func(*Select) []byte {
+		return key
+	})
+}
+
+// validate implements the "VALIDATE" instruction
+func (c *Card) validate(ctx context.Context, deriveKey func(sel *Select) []byte) error {
 	var myChallenge, tokenResponse, tokenResponseExpected []byte
 
-	sel, err := c.Select()
+	sel, err := c.selectApplet(ctx)
 	if err != nil {
 		return err
 	}
@@ -65,7 +172,7 @@ func (c *Card) Validate(code []byte) error {
 
 	tokenChallenge := sel.Challenge
 	alg := Algorithm(sel.Algorithm[0])
-	key := pbkdf2.Key(code, sel.Name, 1000, 16, alg.Hash())
+	key := deriveKey(sel)
 
 	mac := hmac.New(alg.Hash(), key)
 	mac.Write(tokenChallenge)
@@ -76,7 +183,7 @@ func (c *Card) Validate(code []byte) error {
 		return fmt.Errorf("failed to generate challenge: %w", err)
 	}
 
-	tvs, err := c.send(insValidate, 0x00, 0x00,
+	tvs, err := c.send(ctx, insValidate, 0x00, 0x00,
 		tlv.New(tagResponse, myResponse),
 		tlv.New(tagChallenge, myChallenge),
 	)
@@ -102,5 +209,64 @@ func (c *Card) Validate(code []byte) error {
 		return errTokenResponse
 	}
 
+	c.authenticated = true
+
+	return nil
+}
+
+// IsWrongKey checks if the validation failed because of a wrong password or access key.
+// Depending on the firmware, the applet indicates this either with ErrWrongSyntax
+// or ErrResponseDoesNotMatch.
+func IsWrongKey(err error) bool {
+	return errors.Is(err, ErrWrongSyntax) || errors.Is(err, ErrResponseDoesNotMatch)
+}
+
+// authenticate validates the session with the access key returned by the KeyProvider
+// or the password returned by the PasswordProvider
+func (c *Card) authenticate(ctx context.Context) error {
+	sel := c.selected
+	if sel == nil {
+		var err error
+		if sel, err = c.selectApplet(ctx); err != nil {
+			return err
+		}
+	}
+
+	if c.KeyProvider != nil {
+		key, err := c.KeyProvider(sel)
+		if err != nil {
+			return fmt.Errorf("failed to get access key: %w", err)
+		}
+
+		if key != nil {
+			err := c.validate(ctx, func(*Select) []byte {
+				return key
+			})
+			switch {
+			case err == nil:
+				return nil
+
+			// Fall back to the password if the access key has been changed
+			case !IsWrongKey(err) || c.PasswordProvider == nil:
+				return fmt.Errorf("failed to authenticate: %w", err)
+			}
+		}
+	}
+
+	if c.PasswordProvider == nil {
+		return ErrAuthRequired
+	}
+
+	pw, err := c.PasswordProvider(sel)
+	if err != nil {
+		return fmt.Errorf("failed to get password: %w", err)
+	}
+
+	if err := c.validate(ctx, func(sel *Select) []byte {
+		return DeriveAccessKey(pw, sel.Salt())
+	}); err != nil {
+		return fmt.Errorf("failed to authenticate: %w", err)
+	}
+
 	return nil
 }
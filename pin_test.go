@@ -4,15 +4,18 @@
 package ykoath_test
 
 import (
+	"errors"
 	"testing"
 
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
 	ykoath "cunicu.li/go-ykoath/v2"
+	"cunicu.li/go-ykoath/v2/emulator"
 )
 
 func TestPIN(t *testing.T) {
-	withCard(t, nil, func(t *testing.T, card *ykoath.Card) {
+	withEmulator(t, emulator.NewCard(), nil, func(t *testing.T, card *ykoath.Card) {
 		require := require.New(t)
 
 		// Validate should fail if not PIN is set
@@ -28,6 +31,7 @@ func TestPIN(t *testing.T) {
 		// Set PIN
 		err = card.SetCode([]byte("1338"), ykoath.HmacSha256)
 		require.NoError(err)
+		require.True(card.Authenticated())
 
 		// Reset card to clear authenticated state
 		// err = test.ResetCard(card.Card)
@@ -36,6 +40,7 @@ func TestPIN(t *testing.T) {
 		// Select applet again
 		sel, err = card.Select()
 		require.NoError(err)
+		require.False(card.Authenticated())
 
 		require.NotNil(sel.Challenge)
 		require.Len(sel.Algorithm, 1)
@@ -52,9 +57,148 @@ func TestPIN(t *testing.T) {
 		// Test valid PIN
 		err = card.Validate([]byte("1338"))
 		require.NoError(err)
+		require.True(card.Authenticated())
 
 		// RemoveCode should succeed now
 		err = card.RemoveCode()
 		require.NoError(err)
 	})
 }
+
+func TestPasswordProvider(t *testing.T) {
+	withEmulator(t, emulator.NewCard(), vectorsTOTP[:1], func(t *testing.T, card *ykoath.Card) {
+		require := require.New(t)
+
+		err := card.SetCode([]byte("1338"), ykoath.HmacSha256)
+		require.NoError(err)
+
+		// Changing the PIN keeps the session authenticated
+		err = card.SetCode([]byte("1339"), ykoath.HmacSha256)
+		require.NoError(err)
+		require.True(card.Authenticated())
+
+		_, err = card.Select()
+		require.NoError(err)
+		require.False(card.Authenticated())
+
+		// Without a provider, the error is passed to the caller
+		_, err = card.List()
+		require.ErrorIs(err, ykoath.ErrAuthRequired)
+
+		errNoPassword := errors.New("no password")
+		card.PasswordProvider = func(*ykoath.Select) ([]byte, error) {
+			return nil, errNoPassword
+		}
+
+		_, err = card.List()
+		require.ErrorIs(err, errNoPassword)
+		require.False(card.Authenticated())
+
+		var calls int
+		card.PasswordProvider = func(sel *ykoath.Select) ([]byte, error) {
+			require.NotNil(sel.Challenge)
+			calls++
+			return []byte("1339"), nil
+		}
+
+		names, err := card.List()
+		require.NoError(err)
+		require.Len(names, 1)
+		require.True(card.Authenticated())
+
+		// The provider is not consulted for an authenticated session
+		names, err = card.List()
+		require.NoError(err)
+		require.Len(names, 1)
+		require.Equal(1, calls)
+	})
+}
+
+func TestDeriveAccessKey(t *testing.T) {
+	assert := assert.New(t)
+
+	salt := fromHex("f8faaa1d91918e28")
+
+	key := ykoath.DeriveAccessKey([]byte("password"), salt)
+	assert.Equal(fromHex("1dcfcc438ea8712ec02630dc212bb3de"), key)
+
+	sel := &ykoath.Select{Name: salt}
+	assert.Equal("o0BqpXXxUjHr5xb0m7oZVA", sel.DeviceID())
+}
+
+func TestAccessKey(t *testing.T) {
+	withEmulator(t, emulator.NewCard(), nil, func(t *testing.T, card *ykoath.Card) {
+		require := require.New(t)
+
+		sel, err := card.Select()
+		require.NoError(err)
+
+		key := ykoath.DeriveAccessKey([]byte("1338"), sel.Salt())
+
+		err = card.SetKey(key, ykoath.HmacSha256)
+		require.NoError(err)
+
+		_, err = card.Select()
+		require.NoError(err)
+
+		// The derived key is equivalent to the PIN
+		err = card.Validate([]byte("1338"))
+		require.NoError(err)
+
+		err = card.ValidateKey(key)
+		require.NoError(err)
+		require.True(card.Authenticated())
+
+		err = card.ValidateKey(make([]byte, 16))
+		require.ErrorIs(err, ykoath.ErrWrongSyntax)
+		require.False(card.Authenticated())
+
+		err = card.ValidateKey(key)
+		require.NoError(err)
+
+		err = card.RemoveCode()
+		require.NoError(err)
+	})
+}
+
+func TestKeyProvider(t *testing.T) {
+	withEmulator(t, emulator.NewCard(), vectorsTOTP[:1], func(t *testing.T, card *ykoath.Card) {
+		require := require.New(t)
+
+		sel, err := card.Select()
+		require.NoError(err)
+
+		err = card.SetCode([]byte("1338"), ykoath.HmacSha1)
+		require.NoError(err)
+
+		_, err = card.Select()
+		require.NoError(err)
+
+		// A stale key falls back to the password provider
+		var passwords int
+		card.KeyProvider = func(*ykoath.Select) ([]byte, error) {
+			return ykoath.DeriveAccessKey([]byte("1337"), sel.Salt()), nil
+		}
+		card.PasswordProvider = func(*ykoath.Select) ([]byte, error) {
+			passwords++
+			return []byte("1338"), nil
+		}
+
+		_, err = card.List()
+		require.NoError(err)
+		require.Equal(1, passwords)
+
+		_, err = card.Select()
+		require.NoError(err)
+
+		card.KeyProvider = func(s *ykoath.Select) ([]byte, error) {
+			require.Equal(sel.DeviceID(), s.DeviceID())
+			return ykoath.DeriveAccessKey([]byte("1338"), s.Salt()), nil
+		}
+
+		_, err = card.List()
+		require.NoError(err)
+		require.Equal(1, passwords)
+		require.True(card.Authenticated())
+	})
+}
@@ -4,32 +4,168 @@
 package ykoath
 
 import (
+	"context"
 	"encoding/binary"
 	"errors"
 	"fmt"
+	"time"
 
 	"cunicu.li/go-iso7816/encoding/tlv"
 )
 
-var ErrNameTooLong = errors.New("name too long)")
+var (
+	ErrNameTooLong      = errors.New("name too long)")
+	ErrNameTooShort     = errors.New("name is empty")
+	ErrInvalidName      = errors.New("invalid name")
+	ErrInvalidDigits    = errors.New("invalid number of digits")
+	ErrInvalidAlgorithm = errors.New("invalid algorithm")
+	ErrInvalidType      = errors.New("invalid type")
+	ErrMissingSecret    = errors.New("missing secret")
+	ErrInvalidCounter   = errors.New("counter is only supported by HOTP credentials")
+)
+
+const (
+	// MaxNameLength is the maximum length of a credential name in bytes
+	MaxNameLength = 64
+
+	MinDigits = 6
+	MaxDigits = 8
+)
+
+// CredentialData contains the parameters of an OATH credential
+// which are stored on the card by the "PUT" instruction.
+type CredentialData struct {
+	ID        CredentialID
+	Algorithm Algorithm
+	Type      Type
+	Digits    int
+	Secret    []byte
+	Touch     bool
+	Counter   uint32
+}
+
+// Validate checks the credential data before it is sent to the card.
+// All problems are reported at once as ValidationErrors.
+func (d *CredentialData) Validate() error {
+	var errs ValidationErrors
+
+	invalid := func(field string, err error) {
+		errs = append(errs, &ValidationError{
+			Field: field,
+			Err:   err,
+		})
+	}
+
+	if d.ID.Account == "" {
+		invalid("account", ErrNameTooShort)
+	} else if l := len(d.ID.Marshal()); l > MaxNameLength {
+		invalid("name", fmt.Errorf("%w: (%d > %d)", ErrNameTooLong, l, MaxNameLength))
+	} else if id, err := ParseCredentialID(d.ID.String(), d.Type); err != nil || id.Issuer != d.ID.Issuer || id.Account != d.ID.Account {
+		// The name would be listed with a different issuer or account
+		invalid("account", fmt.Errorf("%w: %q can not be decoded", ErrInvalidName, d.ID.String()))
+	}
+
+	switch d.Algorithm {
+	case HmacSha1, HmacSha256, HmacSha512:
+	default:
+		invalid("algorithm", fmt.Errorf("%w: %s", ErrInvalidAlgorithm, d.Algorithm))
+	}
+
+	switch d.Type {
+	case Hotp:
+		if d.ID.Period != 0 {
+			invalid("period", fmt.Errorf("%w: HOTP credentials have no period", ErrInvalidPeriod))
+		}
+
+	case Totp:
+		if d.ID.Period < 0 || d.ID.Period%time.Second != 0 {
+			invalid("period", fmt.Errorf("%w: must be a positive number of seconds", ErrInvalidPeriod))
+		} else if d.ID.Period > MaxPeriod {
+			invalid("period", fmt.Errorf("%w: must not exceed %s", ErrInvalidPeriod, MaxPeriod))
+		}
+
+		if d.Counter != 0 {
+			invalid("counter", ErrInvalidCounter)
+		}
+
+	default:
+		invalid("type", fmt.Errorf("%w: %s", ErrInvalidType, d.Type))
+	}
+
+	if d.Digits < MinDigits || d.Digits > MaxDigits {
+		invalid("digits", fmt.Errorf("%w: %d not in range %d-%d", ErrInvalidDigits, d.Digits, MinDigits, MaxDigits))
+	}
+
+	if len(d.Secret) == 0 {
+		invalid("secret", ErrMissingSecret)
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+
+	return nil
+}
 
 // Put sends a "PUT" instruction, storing a new / overwriting an existing OATH
 // credentials with an algorithm and type, 6 or 8 digits one-time password,
 // shared secrets and touch-required bit
-func (c *Card) Put(name string, alg Algorithm, typ Type, digits int, key []byte, touch bool, counter uint32) error {
-	if l := len(name); l > 64 {
-		return fmt.Errorf("%w: (%d > 64)", ErrNameTooLong, l)
+func (c *Card) Put(id CredentialID, alg Algorithm, typ Type, digits int, key []byte, touch bool, counter uint32) error {
+	return c.PutContext(context.Background(), id, alg, typ, digits, key, touch, counter)
+}
+
+// PutContext is like Put but aborts when the context is done
+func (c *Card) PutContext(ctx context.Context, id CredentialID, alg Algorithm, typ Type, digits int, key []byte, touch bool, counter uint32) error {
+	return c.PutCredentialContext(ctx, &CredentialData{
+		ID:        id,
+		Algorithm: alg,
+		Type:      typ,
+		Digits:    digits,
+		Secret:    key,
+		Touch:     touch,
+		Counter:   counter,
+	})
+}
+
+// PutCredential validates the credential data and sends a "PUT" instruction,
+// storing a new / overwriting an existing OATH credential.
+func (c *Card) PutCredential(d *CredentialData) error {
+	return c.PutCredentialContext(context.Background(), d)
+}
+
+// PutCredentialContext is like PutCredential but aborts when the context is done
+func (c *Card) PutCredentialContext(ctx context.Context, d *CredentialData) error {
+	if err := d.Validate(); err != nil {
+		return err
+	}
+
+	if err := c.acquire(ctx); err != nil {
+		return err
+	}
+
+	defer c.release()
+
+	if d.Algorithm == HmacSha512 {
+		if err := c.require(FeatureHmacSha512); err != nil {
+			return err
+		}
+	}
+
+	if d.Touch {
+		if err := c.require(FeatureTouch); err != nil {
+			return err
+		}
 	}
 
-	key = shortenKey(key, alg)
+	key := shortenKey(d.Secret, d.Algorithm)
 	key = padKey(key)
 
 	tvs := []tlv.TagValue{
-		tlv.New(tagName, []byte(name)),
-		tlv.New(tagKey, []byte{byte(alg) | byte(typ), byte(digits)}, key),
+		tlv.New(tagName, d.ID.Marshal()),
+		tlv.New(tagKey, []byte{byte(d.Algorithm) | byte(d.Type), byte(d.Digits)}, key),
 	}
 
-	if touch {
+	if d.Touch {
 		tvs = append(tvs, tlv.TagValue{
 			Tag:        tagProperty,
 			Value:      []byte{0x02},
@@ -37,14 +173,14 @@ func (c *Card) Put(name string, alg Algorithm, typ Type, digits int, key []byte,
 		})
 	}
 
-	if counter > 0 {
+	if d.Counter > 0 {
 		tvs = append(tvs, tlv.TagValue{
 			Tag:   tagImf,
-			Value: binary.BigEndian.AppendUint32(nil, counter),
+			Value: binary.BigEndian.AppendUint32(nil, d.Counter),
 		})
 	}
 
-	_, err := c.send(insPut, 0x00, 0x00, tvs...)
+	_, err := c.send(ctx, insPut, 0x00, 0x00, tvs...)
 	return err
 }
 
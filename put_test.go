@@ -4,18 +4,21 @@
 package ykoath_test
 
 import (
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 
 	ykoath "cunicu.li/go-ykoath/v2"
+	"cunicu.li/go-ykoath/v2/emulator"
 )
 
 func TestPut(t *testing.T) {
 	withCard(t, nil, func(t *testing.T, card *ykoath.Card) {
 		require := require.New(t)
 
-		err := card.Put("test", ykoath.HmacSha1, ykoath.Hotp, 6, []byte{1, 2, 3}, false, 0)
+		err := card.Put(ykoath.CredentialID{Account: "test"}, ykoath.HmacSha1, ykoath.Hotp, 6, []byte{1, 2, 3}, false, 0)
 		require.NoError(err)
 	})
 }
@@ -24,7 +27,108 @@ func TestPutNameTooLong(t *testing.T) {
 	withCard(t, nil, func(t *testing.T, card *ykoath.Card) {
 		require := require.New(t)
 
-		err := card.Put("0123456789012345678901234567890123456789012345678901234567890123456789", ykoath.HmacSha1, ykoath.Hotp, 6, []byte{1, 2, 3}, false, 0)
+		err := card.Put(ykoath.CredentialID{Account: "0123456789012345678901234567890123456789012345678901234567890123456789"}, ykoath.HmacSha1, ykoath.Hotp, 6, []byte{1, 2, 3}, false, 0)
 		require.ErrorIs(err, ykoath.ErrNameTooLong)
 	})
 }
+
+func TestPutInvalid(t *testing.T) {
+	withEmulator(t, emulator.NewCard(), nil, func(t *testing.T, card *ykoath.Card) {
+		require := require.New(t)
+
+		// No instruction is sent to the card if the credential is invalid
+		err := card.PutCredential(&ykoath.CredentialData{
+			ID: ykoath.CredentialID{
+				Account: "test",
+			},
+			Algorithm: ykoath.HmacSha1,
+			Type:      ykoath.Totp,
+			Digits:    10,
+			Counter:   1,
+		})
+		require.ErrorIs(err, ykoath.ErrInvalidDigits)
+		require.ErrorIs(err, ykoath.ErrMissingSecret)
+		require.ErrorIs(err, ykoath.ErrInvalidCounter)
+
+		names, err := card.List()
+		require.NoError(err)
+		require.Empty(names)
+	})
+}
+
+func TestCredentialDataValidate(t *testing.T) {
+	require := require.New(t)
+
+	valid := ykoath.CredentialData{
+		ID: ykoath.CredentialID{
+			Issuer:  "Example",
+			Account: "alice@example.com",
+			Period:  15 * time.Second,
+		},
+		Algorithm: ykoath.HmacSha256,
+		Type:      ykoath.Totp,
+		Digits:    8,
+		Secret:    testSecretSHA256,
+	}
+
+	d := valid
+	require.NoError(d.Validate())
+
+	d = valid
+	d.Type = ykoath.Hotp
+	d.Counter = 42
+	require.ErrorIs(d.Validate(), ykoath.ErrInvalidPeriod)
+
+	d.ID.Period = 0
+	require.NoError(d.Validate())
+
+	d = valid
+	d.ID.Period = 1500 * time.Millisecond
+	require.ErrorIs(d.Validate(), ykoath.ErrInvalidPeriod)
+
+	d.ID.Period = ykoath.MaxPeriod + time.Second
+	require.ErrorIs(d.Validate(), ykoath.ErrInvalidPeriod)
+
+	// The limit applies to the marshaled name including issuer and period
+	d = valid
+	d.ID.Account = strings.Repeat("a", 60)
+	require.ErrorIs(d.Validate(), ykoath.ErrNameTooLong)
+
+	d.ID.Issuer = ""
+	d.ID.Period = 0
+	require.NoError(d.Validate())
+
+	// Names which would be listed with a different issuer or account
+	for _, account := range []string{"0/foo", "30/foo", "Example:alice"} {
+		d = valid
+		d.ID = ykoath.CredentialID{Account: account}
+		require.ErrorIs(d.Validate(), ykoath.ErrInvalidName, account)
+	}
+
+	d = ykoath.CredentialData{
+		Algorithm: 0x07,
+		Type:      0x30,
+		Digits:    5,
+	}
+
+	err := d.Validate()
+
+	var errs ykoath.ValidationErrors
+	require.ErrorAs(err, &errs)
+	require.Len(errs, 5)
+
+	fields := []string{}
+	for _, e := range errs {
+		fields = append(fields, e.Field)
+	}
+	require.Equal([]string{"account", "algorithm", "type", "digits", "secret"}, fields)
+
+	var verr *ykoath.ValidationError
+	require.ErrorAs(err, &verr)
+	require.Equal("account", verr.Field)
+	require.ErrorIs(err, ykoath.ErrNameTooShort)
+	require.ErrorIs(err, ykoath.ErrInvalidAlgorithm)
+	require.ErrorIs(err, ykoath.ErrInvalidType)
+	require.ErrorIs(err, ykoath.ErrInvalidDigits)
+	require.ErrorIs(err, ykoath.ErrMissingSecret)
+}
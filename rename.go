@@ -0,0 +1,58 @@
+// SPDX-FileCopyrightText: 2023 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package ykoath
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"cunicu.li/go-iso7816/encoding/tlv"
+)
+
+var ErrNameExists = errors.New("name already exists")
+
+// Rename sends a "RENAME" instruction, changing the name of an existing OATH
+// credential while keeping its secret.
+// This instruction requires firmware 5.3.1 or newer.
+func (c *Card) Rename(oldName, newName string) error {
+	return c.RenameContext(context.Background(), oldName, newName)
+}
+
+// RenameContext is like Rename but aborts when the context is done
+func (c *Card) RenameContext(ctx context.Context, oldName, newName string) error {
+	for _, name := range []string{oldName, newName} {
+		if name == "" {
+			return ErrNameTooShort
+		} else if l := len(name); l > MaxNameLength {
+			return fmt.Errorf("%w: (%d > %d)", ErrNameTooLong, l, MaxNameLength)
+		}
+	}
+
+	if err := c.acquire(ctx); err != nil {
+		return err
+	}
+
+	defer c.release()
+
+	if err := c.require(FeatureRename); err != nil {
+		return err
+	}
+
+	_, err := c.send(ctx, insRename, 0x00, 0x00,
+		tlv.New(tagName, []byte(oldName)),
+		tlv.New(tagName, []byte(newName)),
+	)
+
+	switch {
+	case errors.Is(err, ErrNoSuchObject):
+		return fmt.Errorf("%w: %s: %w", ErrUnknownName, oldName, err)
+
+	case errors.Is(err, ErrWrongSyntax):
+		return fmt.Errorf("%w: %s: %w", ErrNameExists, newName, err)
+
+	default:
+		return err
+	}
+}
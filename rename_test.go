@@ -0,0 +1,61 @@
+// SPDX-FileCopyrightText: 2023 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package ykoath_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	ykoath "cunicu.li/go-ykoath/v2"
+	"cunicu.li/go-ykoath/v2/emulator"
+)
+
+func TestRename(t *testing.T) {
+	vs := vectorsTOTP[:1]
+	withEmulator(t, emulator.NewCard(), vs, func(t *testing.T, card *ykoath.Card) {
+		require := require.New(t)
+
+		err := card.Rename(vs[0].Name, strings.Repeat("a", 65))
+		require.ErrorIs(err, ykoath.ErrNameTooLong)
+
+		err = card.Rename(vs[0].Name, "")
+		require.ErrorIs(err, ykoath.ErrNameTooShort)
+
+		err = card.Rename("", "Example:alice@example.com")
+		require.ErrorIs(err, ykoath.ErrNameTooShort)
+
+		err = card.Rename(vs[0].Name, "Example:alice@example.com")
+		require.NoError(err)
+
+		names, err := card.List()
+		require.NoError(err)
+		require.Len(names, 1)
+		require.Equal("Example:alice@example.com", names[0].Name)
+		require.Equal(vs[0].Alg, names[0].Algorithm)
+		require.Equal(vs[0].Typ, names[0].Type)
+	})
+}
+
+func TestRenameUnknownName(t *testing.T) {
+	withEmulator(t, emulator.NewCard(), nil, func(t *testing.T, card *ykoath.Card) {
+		require := require.New(t)
+
+		err := card.Rename("missing", "renamed")
+		require.ErrorIs(err, ykoath.ErrUnknownName)
+		require.ErrorIs(err, ykoath.ErrNoSuchObject)
+	})
+}
+
+func TestRenameNameExists(t *testing.T) {
+	vs := vectorsTOTP[:2]
+	withEmulator(t, emulator.NewCard(), vs, func(t *testing.T, card *ykoath.Card) {
+		require := require.New(t)
+
+		err := card.Rename(vs[0].Name, vs[1].Name)
+		require.ErrorIs(err, ykoath.ErrNameExists)
+		require.ErrorIs(err, ykoath.ErrWrongSyntax)
+	})
+}
@@ -3,10 +3,30 @@
 
 package ykoath
 
+import "context"
+
 // Reset resets the application to just-installed state.
 // This command requires no authentication.
 // WARNING: This function wipes all secrets on the token. Use with care!
 func (c *Card) Reset() error {
-	_, err := c.send(insReset, 0xde, 0xad)
-	return err
+	return c.ResetContext(context.Background())
+}
+
+// ResetContext is like Reset but aborts when the context is done
+func (c *Card) ResetContext(ctx context.Context) error {
+	if err := c.acquire(ctx); err != nil {
+		return err
+	}
+
+	defer c.release()
+
+	if _, err := c.send(ctx, insReset, 0xde, 0xad); err != nil {
+		return err
+	}
+
+	// The reset generates a new salt and removes the password
+	c.selected = nil
+	c.authenticated = false
+
+	return nil
 }
@@ -4,6 +4,9 @@
 package ykoath
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
 	"fmt"
 
 	iso "cunicu.li/go-iso7816"
@@ -15,7 +18,7 @@ type Select struct {
 	Algorithm []byte
 	Challenge []byte
 	Name      []byte
-	Version   []byte
+	Version   Version
 }
 
 func (s *Select) UnmarshalBinary(b []byte) error {
@@ -36,7 +39,9 @@ func (s *Select) UnmarshalBinary(b []byte) error {
 			s.Name = tv.Value
 
 		case tagVersion:
-			s.Version = tv.Value
+			if err := s.Version.UnmarshalBinary(tv.Value); err != nil {
+				return err
+			}
 
 		default:
 			return fmt.Errorf("%w (%#x)", errUnknownTag, tv.Tag)
@@ -46,13 +51,53 @@ func (s *Select) UnmarshalBinary(b []byte) error {
 	return nil
 }
 
+// Salt returns the salt used for deriving the access key from a password.
+// It is generated randomly whenever the applet is reset.
+func (s *Select) Salt() []byte {
+	return s.Name
+}
+
+// DeviceID returns an identifier of the applet which is derived from its salt.
+// It is compatible with the device ID used by ykman and changes whenever the applet is reset.
+func (s *Select) DeviceID() string {
+	h := sha256.Sum256(s.Salt())
+	return base64.RawStdEncoding.EncodeToString(h[:16])
+}
+
 // Select sends a "SELECT" instruction, initializing the device for an OATH session
 func (c *Card) Select() (*Select, error) {
-	resp, err := c.Card.Select(iso.AidYubicoOATH)
+	return c.SelectContext(context.Background())
+}
+
+// SelectContext is like Select but aborts when the context is done
+func (c *Card) SelectContext(ctx context.Context) (*Select, error) {
+	if err := c.acquire(ctx); err != nil {
+		return nil, err
+	}
+
+	defer c.release()
+
+	return c.selectApplet(ctx)
+}
+
+// selectApplet implements the "SELECT" instruction
+func (c *Card) selectApplet(ctx context.Context) (*Select, error) {
+	resp, err := c.exchange(ctx, func() ([]byte, error) {
+		return c.Card.Select(iso.AidYubicoOATH)
+	})
 	if err != nil {
 		return nil, wrapError(err)
 	}
 
 	s := &Select{}
-	return s, s.UnmarshalBinary(resp)
+	if err := s.UnmarshalBinary(resp); err != nil {
+		return nil, err
+	}
+
+	// Selecting the applet ends an authenticated session
+	c.version = s.Version
+	c.selected = s
+	c.authenticated = false
+
+	return s, nil
 }
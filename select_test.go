@@ -21,6 +21,10 @@ func TestSelect(t *testing.T) {
 		assert.Empty(res.Algorithm)
 		assert.Empty(res.Challenge)
 		assert.Len(res.Name, 8) // Name gets regenerated during each applet reset
-		assert.Equal([]byte{0x05, 0x04, 0x03}, res.Version)
+		assert.Equal(res.Name, res.Salt())
+		assert.Len(res.DeviceID(), 22)
+		assert.Equal(ykoath.Version{Major: 5, Minor: 4, Patch: 3}, res.Version)
+		assert.Equal(res.Version, card.Version())
+		assert.True(card.Supports(ykoath.FeatureRename))
 	})
 }
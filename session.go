@@ -0,0 +1,237 @@
+// SPDX-FileCopyrightText: 2023 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package ykoath
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	iso "cunicu.li/go-iso7816"
+)
+
+// errNotSelected is returned by the card if the applet has been
+// deselected, e.g. because the card has been reset by another application.
+var errNotSelected = Error(iso.ErrUnsupportedInstruction)
+
+// Session maintains a connection to a card and transparently re-establishes it
+// if the card has been removed or reset, e.g. by another application like gpg-agent.
+//
+// After reconnecting, the applet is selected again. Authentication is restored by
+// the KeyProvider or PasswordProvider which should hence be configured by Setup.
+// Idempotent operations are retried once on the new connection.
+//
+// A Session is safe for concurrent use by multiple goroutines.
+type Session struct {
+	// Open connects to the card.
+	// It is invoked for the first operation and whenever the connection has been lost.
+	Open func() (iso.PCSCCard, error)
+
+	// Setup is invoked for every newly opened Card in order to configure it,
+	// e.g. by setting its Clock or KeyProvider.
+	Setup func(c *Card)
+
+	mu       sync.Mutex
+	pcscCard iso.PCSCCard
+	card     *Card
+}
+
+// NewSession creates a new session which connects to the card via open.
+// The connection is established lazily by the first operation.
+func NewSession(open func() (iso.PCSCCard, error), setup func(c *Card)) *Session {
+	return &Session{
+		Open:  open,
+		Setup: setup,
+	}
+}
+
+// Close terminates the session and disconnects from the card.
+func (s *Session) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.disconnect()
+}
+
+// Do invokes fn with a connected card which has the applet selected.
+// If fn fails because the connection to the card has been lost, the card is
+// reopened. fn is retried once on the new connection if it is idempotent.
+// Otherwise, the error is returned as it is unknown whether the card has
+// executed the operation.
+func (s *Session) Do(ctx context.Context, idempotent bool, fn func(c *Card) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	card, err := s.connect(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err = fn(card); err == nil || !isConnectionLost(err) {
+		return err
+	}
+
+	// The connection is broken already
+	s.disconnect() //nolint:errcheck
+
+	if !idempotent {
+		return err
+	}
+
+	if card, err = s.connect(ctx); err != nil {
+		return fmt.Errorf("failed to reconnect: %w", err)
+	}
+
+	return fn(card)
+}
+
+// Select returns the result of selecting the applet.
+func (s *Session) Select() (sel *Select, err error) {
+	return s.SelectContext(context.Background())
+}
+
+// SelectContext is like Select but aborts when the context is done
+func (s *Session) SelectContext(ctx context.Context) (sel *Select, err error) {
+	err = s.Do(ctx, true, func(c *Card) (err error) {
+		sel, err = c.SelectContext(ctx)
+		return err
+	})
+
+	return sel, err
+}
+
+// List is like Card.List but reconnects if needed.
+func (s *Session) List() ([]*Name, error) {
+	return s.ListContext(context.Background())
+}
+
+// ListContext is like List but aborts when the context is done
+func (s *Session) ListContext(ctx context.Context) (names []*Name, err error) {
+	err = s.Do(ctx, true, func(c *Card) (err error) {
+		names, err = c.ListContext(ctx)
+		return err
+	})
+
+	return names, err
+}
+
+// CalculateAll is like Card.CalculateAll but reconnects if needed.
+func (s *Session) CalculateAll() ([]*Calculation, error) {
+	return s.CalculateAllContext(context.Background())
+}
+
+// CalculateAllContext is like CalculateAll but aborts when the context is done
+func (s *Session) CalculateAllContext(ctx context.Context) (calcs []*Calculation, err error) {
+	err = s.Do(ctx, true, func(c *Card) (err error) {
+		calcs, err = c.CalculateAllContext(ctx)
+		return err
+	})
+
+	return calcs, err
+}
+
+// Calculate is like Card.Calculate but reconnects if needed.
+// Calculations of HOTP credentials are not retried as the card might have
+// incremented the counter already before the connection has been lost.
+func (s *Session) Calculate(name string) (string, error) {
+	return s.CalculateContext(context.Background(), name)
+}
+
+// CalculateContext is like Calculate but aborts when the context is done
+func (s *Session) CalculateContext(ctx context.Context, name string) (code string, err error) {
+	calculate := func(c *Card) (err error) {
+		code, err = c.CalculateContext(ctx, name)
+		return err
+	}
+
+	if err = s.Do(ctx, false, calculate); err == nil || !isConnectionLost(err) {
+		return code, err
+	}
+
+	// The type of the credential is unknown until it has been listed
+	lostErr, hotp := err, false
+
+	if err = s.Do(ctx, true, func(c *Card) error {
+		names, err := c.ListContext(ctx)
+		if err != nil {
+			return err
+		}
+
+		for _, n := range names {
+			if n.Name == name && n.Type == Hotp {
+				hotp = true
+				return nil
+			}
+		}
+
+		return calculate(c)
+	}); err != nil {
+		return "", err
+	}
+
+	if hotp {
+		return "", lostErr
+	}
+
+	return code, nil
+}
+
+func (s *Session) connect(ctx context.Context) (*Card, error) {
+	if s.card != nil {
+		return s.card, nil
+	}
+
+	pcscCard, err := s.Open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open card: %w", err)
+	}
+
+	card, err := NewCard(pcscCard)
+	if err != nil {
+		pcscCard.Close() //nolint:errcheck
+
+		return nil, err
+	}
+
+	if s.Setup != nil {
+		s.Setup(card)
+	}
+
+	if _, err := card.SelectContext(ctx); err != nil {
+		card.Close()     //nolint:errcheck
+		pcscCard.Close() //nolint:errcheck
+
+		return nil, fmt.Errorf("failed to select applet: %w", err)
+	}
+
+	s.card = card
+	s.pcscCard = pcscCard
+
+	return card, nil
+}
+
+// disconnect ends the session and closes the connection to the card.
+func (s *Session) disconnect() error {
+	if s.card == nil {
+		return nil
+	}
+
+	err := errors.Join(s.card.Close(), s.pcscCard.Close())
+
+	s.card = nil
+	s.pcscCard = nil
+
+	if err != nil {
+		return fmt.Errorf("failed to close card: %w", err)
+	}
+
+	return nil
+}
+
+// isConnectionLost checks if an error has been caused by a lost connection to the card
+// or by a reset of the card which deselected the applet.
+func isConnectionLost(err error) bool {
+	return errors.Is(err, ErrCommunication) || errors.Is(err, errNotSelected)
+}
@@ -0,0 +1,137 @@
+// SPDX-FileCopyrightText: 2023 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package ykoath_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	iso "cunicu.li/go-iso7816"
+	"github.com/stretchr/testify/require"
+
+	ykoath "cunicu.li/go-ykoath/v2"
+	"cunicu.li/go-ykoath/v2/emulator"
+)
+
+func TestSession(t *testing.T) {
+	require := require.New(t)
+
+	emu := emulator.NewCard()
+	opened := 0
+
+	password := []byte("secret")
+
+	sess := ykoath.NewSession(func() (iso.PCSCCard, error) {
+		opened++
+		return emu, nil
+	}, func(c *ykoath.Card) {
+		c.Clock = func() time.Time {
+			return time.Unix(59, 0)
+		}
+
+		c.PasswordProvider = func(*ykoath.Select) ([]byte, error) {
+			return password, nil
+		}
+	})
+
+	defer sess.Close()
+
+	err := sess.Do(context.Background(), false, func(c *ykoath.Card) error {
+		if err := c.Put(ykoath.CredentialID{Account: "testvector"}, ykoath.HmacSha1, ykoath.Totp, 8, []byte("12345678901234567890"), false, 0); err != nil {
+			return err
+		}
+
+		return c.SetCode(password, ykoath.HmacSha1)
+	})
+	require.NoError(err)
+	require.Equal(1, opened)
+
+	// Another application resets the card which deselects the applet
+	err = emu.Reconnect(true)
+	require.NoError(err)
+
+	code, err := sess.Calculate("testvector")
+	require.NoError(err)
+	require.Equal("94287082", code)
+	require.Equal(2, opened)
+
+	// The card is unplugged and plugged in again
+	emu.Remove()
+	emu.Insert()
+
+	names, err := sess.List()
+	require.NoError(err)
+	require.Len(names, 1)
+	require.Equal(3, opened)
+
+	// Operations which are not idempotent are not retried
+	emu.Remove()
+
+	err = sess.Do(context.Background(), false, func(c *ykoath.Card) error {
+		return c.Delete("testvector")
+	})
+	require.ErrorIs(err, ykoath.ErrCommunication)
+	require.Equal(3, opened)
+
+	emu.Insert()
+
+	// But the session is re-established for the next operation
+	calcs, err := sess.CalculateAll()
+	require.NoError(err)
+	require.Len(calcs, 1)
+	require.Equal(4, opened)
+
+	// Errors returned by the applet do not cause a reconnect
+	_, err = sess.Calculate("unknown")
+	require.ErrorIs(err, ykoath.ErrNoSuchObject)
+	require.Equal(4, opened)
+
+	// HOTP calculations are not retried as they increment the counter
+	err = sess.Do(context.Background(), false, func(c *ykoath.Card) error {
+		return c.Put(ykoath.CredentialID{Account: "hotp"}, ykoath.HmacSha1, ykoath.Hotp, 6, []byte("12345678901234567890"), false, 0)
+	})
+	require.NoError(err)
+
+	err = emu.Reconnect(true)
+	require.NoError(err)
+
+	_, err = sess.Calculate("hotp")
+	require.Error(err)
+	require.Equal(5, opened)
+
+	counter, ok := emu.Counter("hotp")
+	require.True(ok)
+	require.EqualValues(0, counter)
+
+	code, err = sess.Calculate("hotp")
+	require.NoError(err)
+	require.Equal("755224", code)
+}
+
+func TestSessionRemoved(t *testing.T) {
+	require := require.New(t)
+
+	emu := emulator.NewCard()
+
+	sess := ykoath.NewSession(func() (iso.PCSCCard, error) {
+		return emu, nil
+	}, nil)
+
+	defer sess.Close()
+
+	_, err := sess.Select()
+	require.NoError(err)
+
+	emu.Remove()
+
+	_, err = sess.List()
+	require.ErrorIs(err, emulator.ErrRemoved)
+
+	emu.Insert()
+
+	names, err := sess.List()
+	require.NoError(err)
+	require.Empty(names)
+}
@@ -0,0 +1,86 @@
+// SPDX-FileCopyrightText: 2023 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package ykoath
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// TouchDelay is the time after which a TouchObserver is notified that the card
+// awaits touch for commands which are not known to require touch in advance.
+const TouchDelay = 500 * time.Millisecond
+
+// TouchObserver is notified about the progress of commands which require
+// the user to touch the token.
+//
+// For credentials which are known to require touch, TouchAwaited is called
+// right before the command is sent. For all other calculations, it is called
+// if the card did not respond within TouchDelay.
+// TouchReceived or TouchTimedOut are only called after TouchAwaited.
+type TouchObserver interface {
+	// TouchAwaited is called when the card waits for the user to touch it.
+	TouchAwaited(name string)
+
+	// TouchReceived is called after the user touched the token.
+	// The duration is measured from the start of the command.
+	TouchReceived(name string, d time.Duration)
+
+	// TouchTimedOut is called if the user did not touch the token in time.
+	// The duration is measured from the start of the command.
+	TouchTimedOut(name string, d time.Duration)
+}
+
+// withTouch runs fn and notifies the TouchObserver of the card.
+// If required is false, the observer is only notified if fn takes longer than TouchDelay.
+func (c *Card) withTouch(name string, required bool, fn func() error) error {
+	obs := c.TouchObserver
+	if obs == nil {
+		return fn()
+	}
+
+	var (
+		mu       sync.Mutex
+		awaited  bool
+		finished bool
+		start    = time.Now()
+	)
+
+	if required {
+		obs.TouchAwaited(name)
+		awaited = true
+	} else {
+		timer := time.AfterFunc(TouchDelay, func() {
+			mu.Lock()
+			defer mu.Unlock()
+
+			if !finished {
+				obs.TouchAwaited(name)
+				awaited = true
+			}
+		})
+
+		defer timer.Stop()
+	}
+
+	err := fn()
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	finished = true
+
+	switch {
+	case !awaited:
+
+	case err == nil:
+		obs.TouchReceived(name, time.Since(start))
+
+	case errors.Is(err, ErrTouchTimeout):
+		obs.TouchTimedOut(name, time.Since(start))
+	}
+
+	return err
+}
@@ -0,0 +1,90 @@
+// SPDX-FileCopyrightText: 2023 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package ykoath_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	ykoath "cunicu.li/go-ykoath/v2"
+	"cunicu.li/go-ykoath/v2/emulator"
+)
+
+type touchRecorder struct {
+	events []string
+	took   time.Duration
+}
+
+func (r *touchRecorder) TouchAwaited(name string) {
+	r.events = append(r.events, "awaited "+name)
+}
+
+func (r *touchRecorder) TouchReceived(name string, d time.Duration) {
+	r.events = append(r.events, "received "+name)
+	r.took = d
+}
+
+func (r *touchRecorder) TouchTimedOut(name string, d time.Duration) {
+	r.events = append(r.events, "timed out "+name)
+	r.took = d
+}
+
+func TestTouchObserver(t *testing.T) {
+	require := require.New(t)
+
+	emu := emulator.NewCard()
+
+	card, err := ykoath.NewCard(emu)
+	require.NoError(err)
+
+	defer card.Close()
+
+	obs := &touchRecorder{}
+	card.TouchObserver = obs
+
+	_, err = card.Select()
+	require.NoError(err)
+
+	err = card.Put(ykoath.CredentialID{Account: "plain"}, ykoath.HmacSha1, ykoath.Totp, 6, testSecretSHA1, false, 0)
+	require.NoError(err)
+
+	err = card.Put(ykoath.CredentialID{Account: "touch"}, ykoath.HmacSha1, ykoath.Totp, 6, testSecretSHA1, true, 0)
+	require.NoError(err)
+
+	// Credentials without touch do not notify the observer
+	_, err = card.CalculateMatch("plain")
+	require.NoError(err)
+
+	_, err = card.CalculateAll()
+	require.NoError(err)
+	require.Empty(obs.events)
+
+	// The touch requirement is only known in advance by CalculateMatch
+	touches := 0
+	emu.Touch = func(string) bool {
+		touches++
+		time.Sleep(ykoath.TouchDelay + 100*time.Millisecond)
+		return touches > 1
+	}
+
+	_, err = card.Calculate("touch")
+	require.ErrorIs(err, ykoath.ErrTouchTimeout)
+	require.Equal([]string{"awaited touch", "timed out touch"}, obs.events)
+	require.Greater(obs.took, ykoath.TouchDelay)
+
+	obs.events = nil
+
+	_, _, err = card.CalculateChallengeResponse("touch", []byte{1, 2, 3, 4})
+	require.NoError(err)
+	require.Equal([]string{"awaited touch", "received touch"}, obs.events)
+
+	obs.events = nil
+	emu.Touch = nil
+
+	_, err = card.CalculateMatch("touch")
+	require.NoError(err)
+	require.Equal([]string{"awaited touch", "received touch"}, obs.events)
+}
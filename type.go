@@ -3,7 +3,10 @@
 
 package ykoath
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+)
 
 const (
 	// Hotp describes HMAC based one-time passwords (https://tools.ietf.org/html/rfc4226)
@@ -29,3 +32,18 @@ func (t Type) String() string {
 		return fmt.Sprintf("unknown %x", byte(t))
 	}
 }
+
+// ParseType parses the name of a type like "totp" as used by otpauth:// URIs.
+// The name is case-insensitive.
+func ParseType(s string) (Type, error) {
+	switch strings.ToUpper(s) {
+	case "TOTP":
+		return Totp, nil
+
+	case "HOTP":
+		return Hotp, nil
+
+	default:
+		return 0, fmt.Errorf("%w: %q", ErrInvalidType, s)
+	}
+}
@@ -0,0 +1,158 @@
+// SPDX-FileCopyrightText: 2023 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package ykoath
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrNotSupported   = errors.New("not supported by firmware")
+	ErrInvalidVersion = errors.New("invalid version")
+)
+
+// Version is the firmware version reported by the OATH applet
+type Version struct {
+	Major int
+	Minor int
+	Patch int
+}
+
+// String returns a string representation of the version
+func (v Version) String() string {
+	return fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+}
+
+// Compare returns -1, 0 or +1 depending on whether v is older,
+// equal or newer than w
+func (v Version) Compare(w Version) int {
+	for _, d := range []int{v.Major - w.Major, v.Minor - w.Minor, v.Patch - w.Patch} {
+		switch {
+		case d < 0:
+			return -1
+		case d > 0:
+			return 1
+		}
+	}
+
+	return 0
+}
+
+// Less returns true if v is older than w
+func (v Version) Less(w Version) bool {
+	return v.Compare(w) < 0
+}
+
+// AtLeast returns true if v is equal to or newer than w
+func (v Version) AtLeast(w Version) bool {
+	return v.Compare(w) >= 0
+}
+
+// IsZero returns true if the version is unknown
+func (v Version) IsZero() bool {
+	return v == Version{}
+}
+
+func (v *Version) UnmarshalBinary(b []byte) error {
+	if len(b) != 3 {
+		return fmt.Errorf("%w: expected 3 bytes, got %d", ErrInvalidVersion, len(b))
+	}
+
+	v.Major = int(b[0])
+	v.Minor = int(b[1])
+	v.Patch = int(b[2])
+
+	return nil
+}
+
+func (v Version) MarshalBinary() ([]byte, error) {
+	if v.Major > 0xff || v.Minor > 0xff || v.Patch > 0xff {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidVersion, v)
+	}
+
+	return []byte{byte(v.Major), byte(v.Minor), byte(v.Patch)}, nil
+}
+
+const (
+	// FeatureTouch allows credentials which require the user to touch the token.
+	FeatureTouch Feature = iota
+
+	// FeatureHmacSha512 allows credentials using the HMAC-SHA512 algorithm.
+	FeatureHmacSha512
+
+	// FeatureRename allows renaming credentials via the "RENAME" instruction.
+	FeatureRename
+)
+
+// Feature denotes functionality of the OATH applet which is
+// only available in newer firmware versions
+type Feature int
+
+// String returns a string representation of the feature
+func (f Feature) String() string {
+	switch f {
+	case FeatureTouch:
+		return "touch"
+
+	case FeatureHmacSha512:
+		return "HMAC-SHA512"
+
+	case FeatureRename:
+		return "rename"
+
+	default:
+		return fmt.Sprintf("unknown %d", int(f))
+	}
+}
+
+// Version returns the first firmware version which supports the feature
+// See: https://github.com/Yubico/yubikey-manager/blob/main/yubikit/oath.py
+func (f Feature) Version() Version {
+	switch f {
+	case FeatureTouch:
+		return Version{4, 2, 6}
+
+	case FeatureHmacSha512:
+		return Version{4, 3, 1}
+
+	case FeatureRename:
+		return Version{5, 3, 1}
+
+	default:
+		return Version{}
+	}
+}
+
+// Version returns the firmware version of the OATH applet.
+// It is only known after the applet has been selected.
+func (c *Card) Version() Version {
+	c.acquire(context.Background()) //nolint:errcheck
+	defer c.release()
+
+	return c.version
+}
+
+// Supports returns true if the firmware of the selected applet supports the feature.
+func (c *Card) Supports(f Feature) bool {
+	c.acquire(context.Background()) //nolint:errcheck
+	defer c.release()
+
+	return c.supports(f)
+}
+
+func (c *Card) supports(f Feature) bool {
+	return !c.version.IsZero() && c.version.AtLeast(f.Version())
+}
+
+// require returns an error if the firmware of the selected applet does not support the feature.
+// If the applet has not been selected yet, the card itself will reject unsupported operations.
+func (c *Card) require(f Feature) error {
+	if c.version.IsZero() || c.supports(f) {
+		return nil
+	}
+
+	return fmt.Errorf("%w: %s requires firmware %s or newer (found %s)", ErrNotSupported, f, f.Version(), c.version)
+}
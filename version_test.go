@@ -0,0 +1,76 @@
+// SPDX-FileCopyrightText: 2023 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package ykoath_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	ykoath "cunicu.li/go-ykoath/v2"
+	"cunicu.li/go-ykoath/v2/emulator"
+)
+
+func TestVersion(t *testing.T) {
+	require := require.New(t)
+
+	var v ykoath.Version
+	err := v.UnmarshalBinary([]byte{5, 4, 3})
+	require.NoError(err)
+	require.Equal(ykoath.Version{Major: 5, Minor: 4, Patch: 3}, v)
+	require.Equal("5.4.3", v.String())
+
+	b, err := v.MarshalBinary()
+	require.NoError(err)
+	require.Equal([]byte{5, 4, 3}, b)
+
+	err = v.UnmarshalBinary([]byte{5, 4})
+	require.ErrorIs(err, ykoath.ErrInvalidVersion)
+
+	require.Equal(0, v.Compare(ykoath.Version{Major: 5, Minor: 4, Patch: 3}))
+	require.Equal(1, v.Compare(ykoath.Version{Major: 5, Minor: 3, Patch: 9}))
+	require.Equal(-1, v.Compare(ykoath.Version{Major: 5, Minor: 4, Patch: 4}))
+	require.Equal(-1, v.Compare(ykoath.Version{Major: 6}))
+
+	require.True(v.Less(ykoath.Version{Major: 5, Minor: 7}))
+	require.False(v.Less(v))
+	require.True(v.AtLeast(v))
+	require.True(v.AtLeast(ykoath.FeatureRename.Version()))
+	require.False(ykoath.Version{Major: 4, Minor: 3, Patch: 0}.AtLeast(ykoath.FeatureHmacSha512.Version()))
+
+	require.True(ykoath.Version{}.IsZero())
+	require.False(v.IsZero())
+}
+
+func TestUnsupportedFeatures(t *testing.T) {
+	emu := emulator.NewCard()
+	emu.Version = ykoath.Version{Major: 4, Minor: 2, Patch: 4}
+
+	withEmulator(t, emu, nil, func(t *testing.T, card *ykoath.Card) {
+		require := require.New(t)
+
+		require.Equal(ykoath.Version{Major: 4, Minor: 2, Patch: 4}, card.Version())
+		require.False(card.Supports(ykoath.FeatureTouch))
+		require.False(card.Supports(ykoath.FeatureHmacSha512))
+		require.False(card.Supports(ykoath.FeatureRename))
+
+		// Unsupported operations are refused without contacting the card
+		err := card.Put(ykoath.CredentialID{Account: "sha512"}, ykoath.HmacSha512, ykoath.Totp, 6, testSecretSHA512, false, 0)
+		require.ErrorIs(err, ykoath.ErrNotSupported)
+
+		err = card.Put(ykoath.CredentialID{Account: "touch"}, ykoath.HmacSha1, ykoath.Totp, 6, testSecretSHA1, true, 0)
+		require.ErrorIs(err, ykoath.ErrNotSupported)
+
+		err = card.Put(ykoath.CredentialID{Account: "test"}, ykoath.HmacSha1, ykoath.Totp, 6, testSecretSHA1, false, 0)
+		require.NoError(err)
+
+		err = card.Rename("test", "renamed")
+		require.ErrorIs(err, ykoath.ErrNotSupported)
+
+		names, err := card.List()
+		require.NoError(err)
+		require.Len(names, 1)
+		require.Equal("test", names[0].Name)
+	})
+}
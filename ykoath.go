@@ -4,6 +4,7 @@
 package ykoath
 
 import (
+	"context"
 	"crypto/rand"
 	"errors"
 	"fmt"
@@ -52,6 +53,17 @@ const (
 
 // Card implements most parts of the TOTP portion of the YKOATH specification
 // https://developers.yubico.com/Card/YKOATH_Protocol.html
+//
+// A Card is safe for concurrent use by multiple goroutines. Its methods are
+// serialized so that multi-command sequences like SELECT and VALIDATE or a
+// CALCULATE following a CALCULATE ALL are not interleaved with others.
+// Commands sent directly via the embedded iso.Card bypass this serialization.
+//
+// The lock does not cover the exported fields Clock, Timestep, Rand,
+// PasswordProvider, KeyProvider and TouchObserver. They configure the Card,
+// must be set before it is used concurrently and must not be changed while
+// other goroutines are using the Card. The providers and the observer are
+// invoked while the Card is locked and must hence not call any of its methods.
 type Card struct {
 	*iso.Card
 
@@ -59,10 +71,40 @@ type Card struct {
 	Timestep time.Duration
 	Rand     io.Reader
 
-	tx *iso.Transaction
+	// PasswordProvider is consulted whenever a command fails because the
+	// applet requires authentication. The returned password is used to
+	// validate the session before the command is retried once.
+	PasswordProvider func(sel *Select) ([]byte, error)
+
+	// KeyProvider is consulted before the PasswordProvider and returns a
+	// previously derived access key. A nil key without an error indicates
+	// that no key is known for the applet.
+	KeyProvider func(sel *Select) ([]byte, error)
+
+	// TouchObserver is notified while calculations wait for the user to touch the token.
+	TouchObserver TouchObserver
+
+	// lock serializes the operations on the card.
+	// It is a channel rather than a mutex in order to honor contexts while waiting.
+	lock chan struct{}
+
+	tx            *iso.Transaction
+	version       Version
+	selected      *Select
+	authenticated bool
+
+	// pending is closed once an exchange which has been abandoned
+	// due to a cancelled context has been completed by the card.
+	pending chan struct{}
 }
 
-var errUnknownTag = errors.New("unknown tag")
+var (
+	// ErrCommunication indicates that a command could not be exchanged with the card,
+	// e.g. because it has been removed or reset by another application.
+	ErrCommunication = errors.New("failed to communicate with card")
+
+	errUnknownTag = errors.New("unknown tag")
+)
 
 // NewCard initializes a new OATH card.
 func NewCard(pcscCard iso.PCSCCard) (*Card, error) {
@@ -80,12 +122,23 @@ func NewCard(pcscCard iso.PCSCCard) (*Card, error) {
 		Timestep: DefaultTimeStep,
 		Rand:     rand.Reader,
 
-		tx: tx,
+		lock: make(chan struct{}, 1),
+		tx:   tx,
 	}, nil
 }
 
-// Close terminates an OATH session
+// Close terminates an OATH session.
+// If an exchange has been abandoned due to a cancelled context,
+// Close waits for the card to complete it before ending the transaction.
 func (c *Card) Close() error {
+	c.acquire(context.Background()) //nolint:errcheck
+	defer c.release()
+
+	if c.pending != nil {
+		<-c.pending
+		c.pending = nil
+	}
+
 	if c.tx != nil {
 		if err := c.tx.EndTransaction(); err != nil {
 			return err
@@ -95,20 +148,49 @@ func (c *Card) Close() error {
 	return nil
 }
 
-func (c *Card) send(ins iso.Instruction, p1, p2 byte, tvsCmd ...tlv.TagValue) (tvsResp []tlv.TagValue, err error) {
+// acquire locks the card for an operation.
+// It blocks until other operations have completed or the context is done.
+func (c *Card) acquire(ctx context.Context) error {
+	select {
+	case c.lock <- struct{}{}:
+		return nil
+
+	case <-ctx.Done():
+		return fmt.Errorf("aborted while waiting for card: %w", ctx.Err())
+	}
+}
+
+// release unlocks the card after an operation has completed.
+func (c *Card) release() {
+	<-c.lock
+}
+
+func (c *Card) send(ctx context.Context, ins iso.Instruction, p1, p2 byte, tvsCmd ...tlv.TagValue) (tvsResp []tlv.TagValue, err error) {
 	data, err := tlv.EncodeSimple(tvsCmd...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to encode command: %w", err)
 	}
 
-	res, err := c.Send(&iso.CAPDU{
-		Ins:  ins,
-		P1:   p1,
-		P2:   p2,
-		Data: data,
-	})
+	transmit := func() ([]byte, error) {
+		return c.Send(&iso.CAPDU{
+			Ins:  ins,
+			P1:   p1,
+			P2:   p2,
+			Data: data,
+		})
+	}
+
+	res, err := c.exchange(ctx, transmit)
+	if err = wrapCommandError(err, ins, tvsCmd); errors.Is(err, ErrAuthRequired) && (c.PasswordProvider != nil || c.KeyProvider != nil) && ins != insValidate {
+		if err := c.authenticate(ctx); err != nil {
+			return nil, err
+		}
+
+		res, err = c.exchange(ctx, transmit)
+		err = wrapCommandError(err, ins, tvsCmd)
+	}
 	if err != nil {
-		return nil, wrapError(err)
+		return nil, err
 	}
 
 	if tvsResp, err = tlv.DecodeSimple(res); err != nil {
@@ -117,3 +199,61 @@ func (c *Card) send(ins iso.Instruction, p1, p2 byte, tvsCmd ...tlv.TagValue) (t
 
 	return tvsResp, nil
 }
+
+// exchange runs a single command/response exchange with the card.
+// When the context is done before the card responded, the exchange is
+// abandoned and the context error is returned. As the card can not be
+// interrupted, the next exchange waits for the abandoned one to complete.
+func (c *Card) exchange(ctx context.Context, fn func() ([]byte, error)) ([]byte, error) {
+	if c.pending != nil {
+		select {
+		case <-c.pending:
+			c.pending = nil
+
+		case <-ctx.Done():
+			return nil, fmt.Errorf("aborted while waiting for pending exchange: %w", ctx.Err())
+		}
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("aborted exchange: %w", err)
+	}
+
+	// Distinguish failures of the transport from status words returned by the card
+	transmit := fn
+	fn = func() ([]byte, error) {
+		res, err := transmit()
+
+		var code iso.Code
+		if err != nil && !errors.As(err, &code) {
+			err = fmt.Errorf("%w: %w", ErrCommunication, err)
+		}
+
+		return res, err
+	}
+
+	// Avoid the overhead of a goroutine for contexts which can not be cancelled
+	if ctx.Done() == nil {
+		return fn()
+	}
+
+	var (
+		res  []byte
+		err  error
+		done = make(chan struct{})
+	)
+
+	go func() {
+		res, err = fn()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return res, err
+
+	case <-ctx.Done():
+		c.pending = done
+		return nil, fmt.Errorf("aborted exchange: %w", ctx.Err())
+	}
+}
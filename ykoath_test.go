@@ -14,39 +14,52 @@ import (
 	"github.com/stretchr/testify/require"
 
 	ykoath "cunicu.li/go-ykoath/v2"
+	"cunicu.li/go-ykoath/v2/emulator"
 )
 
 // withCard is a helper to initialize a card for testing
 func withCard(t *testing.T, vs []vector, cb func(t *testing.T, card *ykoath.Card)) {
 	test.WithCard(t, yk.HasOATH, func(t *testing.T, isoCard *iso.Card) {
-		require := require.New(t)
+		setupCard(t, isoCard, vs, cb)
+	})
+}
 
-		oathCard, err := ykoath.NewCard(isoCard)
-		require.NoError(err)
+// withEmulator is like withCard but runs the test against an emulated applet.
+// It is used for tests for which no traces have been recorded on a real token.
+func withEmulator(t *testing.T, emu *emulator.Card, vs []vector, cb func(t *testing.T, card *ykoath.Card)) {
+	t.Helper()
 
-		_, err = oathCard.Select()
-		require.NoError(err, "Failed to select applet")
+	setupCard(t, emu, vs, cb)
+}
 
-		err = oathCard.Reset()
-		require.NoError(err, "Failed to reset applet")
+func setupCard(t *testing.T, pcscCard iso.PCSCCard, vs []vector, cb func(t *testing.T, card *ykoath.Card)) {
+	require := require.New(t)
 
-		for _, v := range vs {
-			v := v
-			err = oathCard.Put(v.Name, v.Alg, v.Typ, v.Digits, v.Secret, v.Touch, v.Counter)
-			require.NoError(err, "Failed to put credential")
-		}
+	oathCard, err := ykoath.NewCard(pcscCard)
+	require.NoError(err)
 
-		// Fix the clock for our tests
-		oathCard.Clock = func() time.Time {
-			return time.Unix(59, 0)
-		}
+	_, err = oathCard.Select()
+	require.NoError(err, "Failed to select applet")
 
-		// Fix the random source for reproducible tests
-		oathCard.Rand = rand.New(rand.NewSource(4242)) //nolint:gosec
+	err = oathCard.Reset()
+	require.NoError(err, "Failed to reset applet")
 
-		cb(t, oathCard)
+	for _, v := range vs {
+		v := v
+		err = oathCard.Put(ykoath.CredentialID{Account: v.Name}, v.Alg, v.Typ, v.Digits, v.Secret, v.Touch, v.Counter)
+		require.NoError(err, "Failed to put credential")
+	}
 
-		err = oathCard.Close()
-		require.NoError(err)
-	})
+	// Fix the clock for our tests
+	oathCard.Clock = func() time.Time {
+		return time.Unix(59, 0)
+	}
+
+	// Fix the random source for reproducible tests
+	oathCard.Rand = rand.New(rand.NewSource(4242)) //nolint:gosec
+
+	cb(t, oathCard)
+
+	err = oathCard.Close()
+	require.NoError(err)
 }